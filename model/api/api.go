@@ -1,8 +1,8 @@
 package api
 
 import (
-	//"encoding/json"
-	"fmt"
+	"context"
+	"io"
 	"github.com/vituchon/splitify/model"
 	"github.com/vituchon/splitify/repositories"
 	"github.com/vituchon/splitify/util"
@@ -10,185 +10,1089 @@ import (
 )
 
 var (
-	groupsRepository               repositories.EntitiesRepository[*model.Group]
-	participantsRepository         repositories.ParticipantsRepository
-	movementsRepository            repositories.MovementsRepository
-	participantMovementsRepository repositories.ParticipantMovementsRepository
+	storage              repositories.TransactionalStorage // every call reaches it through WithContext, so its queries are cancelled along with the caller's context
+	exchangeRateProvider model.ExchangeRateProvider        // optional, without it movements in a foreign currency must bring their own rate
+	priceIndexProvider   model.PriceIndexProvider          // optional, required only by inflation adjusted groups
 )
 
 func init() {
-	groupsRepository = repositories.NewEntitiesMemoryStorage[*model.Group]()
-	participantsRepository = repositories.NewParticipantsMemoryRepository()
-	movementsRepository = repositories.NewMovementsMemoryRepository()
-	participantMovementsRepository = repositories.NewParticipantMovementsMemoryRepository()
+	useStorage(repositories.NewMemoryStorage())
 }
 
-func CreateGroup(name string) (*model.Group, error) {
+func useStorage(transactionalStorage repositories.TransactionalStorage) {
+	storage = transactionalStorage
+}
+
+// Makes the groups, participants and movements survive restarts by keeping them in files inside the given directory,
+// it is meant to be called at startup before serving any request
+func UseFileStorage(dir string) error {
+	fileStorage, err := repositories.NewFileStorage(dir)
+	if err != nil {
+		return err
+	}
+	useStorage(fileStorage)
+	return nil
+}
+
+// Keeps the groups, participants and movements in a sqlite database file, whose schema is upgraded when opening it,
+// it is meant to be called at startup before serving any request
+func UseSqliteStorage(path string) error {
+	db, err := repositories.OpenSqliteDatabase(path)
+	if err != nil {
+		return err
+	}
+	useStorage(repositories.NewSqlStorage(db, repositories.SqliteDialect{}, 0))
+	return nil
+}
+
+const postgresQueryTimeout = 5 * time.Second
+
+// Keeps the groups, participants and movements in a postgres database shared by several servers, its schema is upgraded when connecting,
+// it is meant to be called at startup before serving any request
+func UsePostgresStorage(dsn string) error {
+	db, err := repositories.OpenPostgresDatabase(dsn)
+	if err != nil {
+		return err
+	}
+	useStorage(repositories.NewSqlStorage(db, repositories.PostgresDialect{}, postgresQueryTimeout))
+	return nil
+}
+
+// Releases the storage in use (e.g. the lock and log of the file storage or the database connections), it is meant to be called
+// at shutdown once no request is being served
+func CloseStorage() error {
+	closer, closable := storage.(io.Closer)
+	if !closable {
+		return nil
+	}
+	return closer.Close()
+}
+
+func SetExchangeRateProvider(provider model.ExchangeRateProvider) {
+	exchangeRateProvider = provider
+}
+
+func SetPriceIndexProvider(provider model.PriceIndexProvider) {
+	priceIndexProvider = provider
+}
+
+func CreateGroup(ctx context.Context, name string) (*model.Group, error) {
+	return CreateGroupWithCurrency(ctx, name, model.DefaultCurrency)
+}
+
+func CreateGroupWithCurrency(ctx context.Context, name string, currency model.Currency) (*model.Group, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	err := model.EnsureCurrencyIsValid(currency)
+	if err != nil {
+		return nil, err
+	}
 	group := &model.Group{
-		Name: name,
+		Name:     name,
+		Currency: currency,
 	}
-	return groupsRepository.Save(group)
+	return repos.Groups.Save(group)
 }
 
-func GetAllGroups() ([]*model.Group, error) {
-	return groupsRepository.GetAll()
+func GetGroup(ctx context.Context, groupId int) (*model.Group, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	return repos.Groups.GetById(groupId)
+}
+
+func GetAllGroups(ctx context.Context) ([]*model.Group, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	return repos.Groups.GetAll()
+}
+
+func SetGroupRemainderPolicy(ctx context.Context, groupId int, policy model.RemainderPolicy) (*model.Group, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	err := model.EnsureRemainderPolicyIsSupported(policy)
+	if err != nil {
+		return nil, err
+	}
+	group, err := repos.Groups.GetById(groupId)
+	if err != nil {
+		return nil, err
+	}
+	group.RemainderPolicy = policy
+	return repos.Groups.Update(group)
+}
+
+// Inflation adjustment can only be enabled when a price index provider is configured (see SetPriceIndexProvider)
+func SetGroupInflationAdjustment(ctx context.Context, groupId int, enabled bool) (*model.Group, error) {
+	if enabled && priceIndexProvider == nil {
+		return nil, model.ErrPriceIndexNotConfigured
+	}
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(groupId)
+	if err != nil {
+		return nil, err
+	}
+	group.InflationAdjusted = enabled
+	return repos.Groups.Update(group)
+}
+
+// Groups participants that settle as a single party, the first participant of each unit pays and receives on behalf of it
+func SetGroupSettlementUnits(ctx context.Context, groupId int, units []model.SettlementUnit) (*model.Group, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	err := model.EnsureSettlementUnitsAreValid(units)
+	if err != nil {
+		return nil, err
+	}
+	group, err := repos.Groups.GetById(groupId)
+	if err != nil {
+		return nil, err
+	}
+	for _, unit := range units {
+		err = ensureParticipantsBelongToGroup(repos, groupId, unit.ParticipantIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	group.SettlementUnits = units
+	return repos.Groups.Update(group)
 }
 
 type Participant struct {
-	GroupId int    `json:"GroupId"`
-	Name    string `json:"name"`
+	GroupId   int                      `json:"GroupId"`
+	Name      string                   `json:"name"`
+	Weight    int                      `json:"weight"`    // optional, how many parts the participant takes by default in equal splits, one when not given
+	Presences []model.PresenceInterval `json:"presences"` // optional, the days the participant was with the group
 }
 
-func AddParticipant(participant Participant) (*model.Participant, error) {
-	_, err := groupsRepository.GetById(participant.GroupId)
+func AddParticipant(ctx context.Context, participant Participant) (*model.Participant, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	_, err := repos.Groups.GetById(participant.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	err = model.EnsurePresenceIntervalsAreValid(participant.Presences)
 	if err != nil {
 		return nil, err
 	}
+	if participant.Weight < 0 {
+		return nil, model.ErrInvalidWeights
+	}
 	p := &model.Participant{
-		GroupId: participant.GroupId,
-		Name:    participant.Name,
+		GroupId:   participant.GroupId,
+		Name:      participant.Name,
+		Weight:    participant.Weight,
+		Presences: participant.Presences,
+	}
+	p.Weight = p.GetWeight()
+	return repos.Participants.Save(p)
+}
+
+// Sets how many parts the participant takes by default in equal splits, movements already entered keep the weights they were split with
+func SetParticipantWeight(ctx context.Context, groupId int, participantId int, weight int) (*model.Participant, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	if weight <= 0 {
+		return nil, model.ErrInvalidWeights
+	}
+	participant, err := repos.Participants.GetById(participantId)
+	if err != nil {
+		return nil, err
+	}
+	if participant.GroupId != groupId {
+		return nil, model.ErrParticipantNotInGroup
+	}
+	participant.Weight = weight
+	return repos.Participants.Update(participant)
+}
+
+// Replaces the days the participant was with the group, movements already entered keep the presence they were split with
+func SetParticipantPresences(ctx context.Context, groupId int, participantId int, presences []model.PresenceInterval) (*model.Participant, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	err := model.EnsurePresenceIntervalsAreValid(presences)
+	if err != nil {
+		return nil, err
 	}
-	return participantsRepository.Save(p)
+	participant, err := repos.Participants.GetById(participantId)
+	if err != nil {
+		return nil, err
+	}
+	if participant.GroupId != groupId {
+		return nil, model.ErrParticipantNotInGroup
+	}
+	participant.Presences = presences
+	return repos.Participants.Update(participant)
 }
 
-func GetParticipants(groupId int) ([]*model.Participant, error) {
-	return participantsRepository.GetByGroupId(groupId)
+func GetParticipants(ctx context.Context, groupId int) ([]*model.Participant, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	return repos.Participants.GetByGroupId(groupId)
 }
 
 
 type ParticipantMovement struct {
-	ParticipantId int         `json:"participantId"`
-	Amount        model.Price `json:"amount"`
+	ParticipantId int                   `json:"participantId"`
+	Amount        model.Money           `json:"amount"`
+	Weight        int                   `json:"weight"`
+	Consumed      model.Money           `json:"consumed"`
+	Role          model.ParticipantRole `json:"role"`
+}
+
+type MovementItem struct {
+	Concept        string         `json:"concept"`
+	Amount         model.Money    `json:"amount"`
+	Kind           model.ItemKind `json:"kind"`
+	ParticipantIds []int          `json:"participantIds"`
+}
+
+// either a percentage (e.g. a 10% tip) or a fixed amount (e.g. the delivery) already included in the movement's amount
+type Surcharge struct {
+	Kind       model.SurchargeKind `json:"kind"`
+	Percentage float64             `json:"percentage"`
+	Amount     model.Money         `json:"amount"`
+}
+
+// caps a participant's part of the movement or makes another participant (the sponsor) take it over
+type ShareRule struct {
+	Kind          model.ShareRuleKind `json:"kind"`
+	ParticipantId int                 `json:"participantId"`
+	Cap           model.Money         `json:"cap"`
+	SponsorId     int                 `json:"sponsorId"`
 }
 
 type Movement struct {
 	GroupId              int                   `json:"groupId"`
-	Amount               model.Price           `json:"amount"`
+	Amount               model.Money           `json:"amount"`
+	ExchangeRate         float64               `json:"exchangeRate"` // optional, units of the group's currency per unit of the amount's currency, taken from the provider when not given
 	Concept              string                `json:"concept"`
+	SplitStrategy        model.SplitStrategy   `json:"splitStrategy"`
+	Installments         int                   `json:"installments"` // optional, more than one makes an installments movement, must not be negative
+	StartsAt             int64                 `json:"startsAt"`     // optional, unix timestamp of the first installment, defaults to now
+	Items                []MovementItem        `json:"items"`        // optional, the lines of an itemised receipt, makes the split strategy default to an itemised one
+	Surcharges           []Surcharge           `json:"surcharges"`   // optional, allocated in proportion to what each participant consumed
+	PeriodFrom           int64                 `json:"periodFrom"`   // optional, unix timestamp of the day (or first day of the period) the movement took place, for presence splits
+	PeriodTo             int64                 `json:"periodTo"`     // optional, unix timestamp of the last day of the period, for presence splits
+	ShareRules           []ShareRule           `json:"shareRules"`   // optional, sponsors not taking part of the movement are added to it
 	ParticipantMovements []ParticipantMovement `json:"participantMovement"`
 }
 
-func GetMovements(groupId int) ([]*model.Movement, error) {
-	return movementsRepository.GetByGroupId(groupId)
+// A recorded movement with its amounts as money in the movement's currency, as the clients see it
+type RecordedMovement struct {
+	Id            int                 `json:"id"`
+	GroupId       int                 `json:"groupId"`
+	CreatedAt     int64               `json:"createdAt"` // unix timestamp, in seconds since epoch
+	Amount        model.Money         `json:"amount"`
+	ExchangeRate  float64             `json:"exchangeRate"` // units of the group's currency per unit of the amount's currency when the movement was entered
+	Concept       string              `json:"concept"`
+	SplitStrategy model.SplitStrategy `json:"splitStrategy"`
+	Kind          model.MovementKind  `json:"kind"`
+	Installments  int                 `json:"installments,omitempty"`
+	StartsAt      int64               `json:"startsAt,omitempty"`
+	Items         []MovementItem      `json:"items,omitempty"`
+	Surcharges    []Surcharge         `json:"surcharges,omitempty"`
+	PeriodFrom    int64               `json:"periodFrom,omitempty"`
+	PeriodTo      int64               `json:"periodTo,omitempty"`
+	ShareRules    []ShareRule         `json:"shareRules,omitempty"`
 }
 
-func GetParticipantMovements(movementId int) ([]*model.ParticipantMovement, error) {
-	return participantMovementsRepository.GetByMovementId(movementId)
+func NewRecordedMovement(movement model.Movement) RecordedMovement {
+	recorded := RecordedMovement{
+		Id:            movement.Id,
+		GroupId:       movement.GroupId,
+		CreatedAt:     movement.CreatedAt,
+		Amount:        movement.GetAmount(),
+		ExchangeRate:  movement.ExchangeRate,
+		Concept:       movement.Concept,
+		SplitStrategy: movement.SplitStrategy,
+		Kind:          movement.Kind,
+		Installments:  movement.Installments,
+		StartsAt:      movement.StartsAt,
+		PeriodFrom:    movement.PeriodFrom,
+		PeriodTo:      movement.PeriodTo,
+	}
+	for _, item := range movement.Items {
+		recorded.Items = append(recorded.Items, MovementItem{
+			Concept:        item.Concept,
+			Amount:         model.NewMoney(item.Amount, movement.Currency),
+			Kind:           item.Kind,
+			ParticipantIds: item.ParticipantIds,
+		})
+	}
+	for _, surcharge := range movement.Surcharges {
+		recorded.Surcharges = append(recorded.Surcharges, Surcharge{
+			Kind:       surcharge.Kind,
+			Percentage: surcharge.Percentage,
+			Amount:     model.NewMoney(surcharge.Amount, movement.Currency),
+		})
+	}
+	for _, rule := range movement.ShareRules {
+		recorded.ShareRules = append(recorded.ShareRules, ShareRule{
+			Kind:          rule.Kind,
+			ParticipantId: rule.ParticipantId,
+			Cap:           model.NewMoney(rule.Cap, movement.Currency),
+			SponsorId:     rule.SponsorId,
+		})
+	}
+	return recorded
+}
+
+func NewRecordedMovements(movements []*model.Movement) []RecordedMovement {
+	recorded := make([]RecordedMovement, 0, len(movements))
+	for _, movement := range movements {
+		recorded = append(recorded, NewRecordedMovement(*movement))
+	}
+	return recorded
 }
 
-func AddMovement(movement Movement) (*model.Movement, []*model.ParticipantMovement, error) {
-	_, err := groupsRepository.GetById(movement.GroupId)
+func GetMovements(ctx context.Context, groupId int) ([]*model.Movement, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	return repos.Movements.GetByGroupId(groupId)
+}
+
+func GetParticipantMovements(ctx context.Context, movementId int) ([]*model.ParticipantMovement, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	return repos.ParticipantMovements.GetByMovementId(movementId)
+}
+
+func AddMovement(ctx context.Context, movement Movement) (*model.Movement, []*model.ParticipantMovement, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(movement.GroupId)
+	if err != nil {
+		return nil, nil, err
+	}
+	participantIds := make([]int, 0, len(movement.ParticipantMovements))
+	for _, participantMovement := range movement.ParticipantMovements {
+		participantIds = append(participantIds, participantMovement.ParticipantId)
+	}
+	for _, rule := range movement.ShareRules {
+		if rule.Kind == model.SponsorRule {
+			participantIds = append(participantIds, rule.SponsorId)
+		}
+	}
+	err = ensureParticipantsBelongToGroup(repos, movement.GroupId, participantIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	createdAt := time.Now()
+	exchangeRate, err := snapshotExchangeRate(*group, movement.Amount.Currency, movement.ExchangeRate, createdAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := &model.Movement{
+		GroupId:       movement.GroupId,
+		Amount:        movement.Amount.Amount,
+		Currency:      movement.Amount.Currency,
+		ExchangeRate:  exchangeRate,
+		CreatedAt:     createdAt.Unix(),
+		Concept:       movement.Concept,
+		SplitStrategy: movement.SplitStrategy,
+		Kind:          model.ExpenseKind,
+		PeriodFrom:    movement.PeriodFrom,
+		PeriodTo:      movement.PeriodTo,
+	}
+	err = model.EnsureMovementAmountIsNotNegative(*m)
 	if err != nil {
 		return nil, nil, err
 	}
+	for _, item := range movement.Items {
+		err = ensureMoniesAreInCurrency(movement.Amount.Currency, item.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		m.Items = append(m.Items, model.MovementItem{
+			Concept:        item.Concept,
+			Amount:         item.Amount.Amount,
+			Kind:           item.Kind,
+			ParticipantIds: item.ParticipantIds,
+		})
+	}
+	surcharges := make([]model.Surcharge, 0, len(movement.Surcharges))
+	for _, surcharge := range movement.Surcharges {
+		err = ensureMoniesAreInCurrency(movement.Amount.Currency, surcharge.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		surcharges = append(surcharges, model.Surcharge{
+			Kind:       surcharge.Kind,
+			Percentage: surcharge.Percentage,
+			Amount:     surcharge.Amount.Amount,
+		})
+	}
+	if len(surcharges) > 0 {
+		m.Surcharges, err = model.ResolveSurcharges(m.Amount, surcharges)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(m.Items) > 0 && m.SplitStrategy == "" {
+		m.SplitStrategy = model.ItemizedSplit
+	}
+	if movement.Installments < 0 {
+		return nil, nil, model.ErrInvalidInstallments
+	}
+	if movement.Installments > 1 {
+		m.Kind = model.InstallmentsKind
+		m.Installments = movement.Installments
+		m.StartsAt = movement.StartsAt
+		if m.StartsAt == 0 {
+			m.StartsAt = m.CreatedAt
+		}
+	}
+	participantMovements := make([]model.ParticipantMovement, 0, len(movement.ParticipantMovements))
 	for _, participantMovement := range movement.ParticipantMovements {
-		participant, err := participantsRepository.GetById(participantMovement.ParticipantId)
+		err = ensureMoniesAreInCurrency(movement.Amount.Currency, participantMovement.Amount, participantMovement.Consumed)
 		if err != nil {
 			return nil, nil, err
 		}
-		if participant.GroupId != movement.GroupId {
-			return nil, nil, fmt.Errorf("Participant(id='%d') doesnt belong to movement's group(id='%d')", participant.Id, movement.GroupId)
+		participantMovements = append(participantMovements, model.ParticipantMovement{
+			ParticipantId: participantMovement.ParticipantId,
+			Amount:        participantMovement.Amount.Amount,
+			Weight:        participantMovement.Weight,
+			Consumed:      participantMovement.Consumed.Amount,
+			Role:          participantMovement.Role,
+		})
+	}
+	err = model.EnsureParticipantRolesAreConsistent(participantMovements)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rule := range movement.ShareRules {
+		err = ensureMoniesAreInCurrency(movement.Amount.Currency, rule.Cap)
+		if err != nil {
+			return nil, nil, err
 		}
+		m.ShareRules = append(m.ShareRules, model.ShareRule{
+			Kind:          rule.Kind,
+			ParticipantId: rule.ParticipantId,
+			Cap:           rule.Cap.Amount,
+			SponsorId:     rule.SponsorId,
+		})
 	}
-	m := &model.Movement{
-		GroupId:   movement.GroupId,
-		Amount:    movement.Amount,
-		CreatedAt: time.Now().Unix(),
-		Concept:   movement.Concept,
+	participantMovements = addMissingSponsors(m.ShareRules, participantMovements)
+	if m.SplitStrategy == "" || m.SplitStrategy == model.EqualSplit {
+		participantMovements, err = fillParticipantsDefaultWeights(repos, participantMovements)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
-	m, err = movementsRepository.Save(m)
+	if m.SplitStrategy == model.PresenceSplit {
+		participants, err := repos.Participants.GetByGroupId(group.Id)
+		if err != nil {
+			return nil, nil, err
+		}
+		participantMovements, err = model.BuildParticipantsPresenceWeights(*m, participantMovements, util.ToValues(participants))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return saveMovement(ctx, *group, m, participantMovements)
+}
+
+// the sponsors that are not part of the movement join it only to take over the share of the participants they sponsor
+func addMissingSponsors(rules []model.ShareRule, participantMovements []model.ParticipantMovement) []model.ParticipantMovement {
+	for _, rule := range rules {
+		if rule.Kind != model.SponsorRule {
+			continue
+		}
+		isPartOfMovement := false
+		for _, participantMovement := range participantMovements {
+			isPartOfMovement = isPartOfMovement || participantMovement.ParticipantId == rule.SponsorId
+		}
+		if !isPartOfMovement {
+			participantMovements = append(participantMovements, model.ParticipantMovement{ParticipantId: rule.SponsorId, Role: model.SponsorRole})
+		}
+	}
+	return participantMovements
+}
+
+// the beneficiaries without a weight for the movement take their participant's default one
+func fillParticipantsDefaultWeights(repos repositories.Repositories, participantMovements []model.ParticipantMovement) ([]model.ParticipantMovement, error) {
+	for i := range participantMovements {
+		if participantMovements[i].Weight != 0 || !participantMovements[i].IsBeneficiary() {
+			continue
+		}
+		participant, err := repos.Participants.GetById(participantMovements[i].ParticipantId)
+		if err != nil {
+			return nil, err
+		}
+		participantMovements[i].Weight = participant.GetWeight()
+	}
+	return participantMovements, nil
+}
+
+type Transfer struct {
+	GroupId           int         `json:"groupId"`
+	FromParticipantId int         `json:"fromParticipantId"`
+	ToParticipantId   int         `json:"toParticipantId"`
+	Amount            model.Money `json:"amount"`
+	ExchangeRate      float64     `json:"exchangeRate"` // optional, same as in Movement
+	Concept           string      `json:"concept"`
+}
+
+// Records that a participant gave money to another one (e.g. a payback), so the giver is owed the amount by the receiver
+func AddTransfer(ctx context.Context, transfer Transfer) (*model.Movement, []*model.ParticipantMovement, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(transfer.GroupId)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ensureParticipantsBelongToGroup(repos, transfer.GroupId, []int{transfer.FromParticipantId, transfer.ToParticipantId})
+	if err != nil {
+		return nil, nil, err
+	}
+	createdAt := time.Now()
+	exchangeRate, err := snapshotExchangeRate(*group, transfer.Amount.Currency, transfer.ExchangeRate, createdAt)
 	if err != nil {
 		return nil, nil, err
 	}
+	transferMovement := model.TransferMovement{
+		Movement: model.Movement{
+			GroupId:      transfer.GroupId,
+			Amount:       transfer.Amount.Amount,
+			Currency:     transfer.Amount.Currency,
+			ExchangeRate: exchangeRate,
+			CreatedAt:    createdAt.Unix(),
+			Concept:      transfer.Concept,
+			Kind:         model.TransferKind,
+		},
+		FromParticipantId: transfer.FromParticipantId,
+		ToParticipantId:   transfer.ToParticipantId,
+	}
+	return saveTransferMovement(ctx, *group, transferMovement)
+}
 
-	pms := make([]*model.ParticipantMovement, 0, len(movement.ParticipantMovements))
-	for _, participantMovement := range movement.ParticipantMovements {
-		pm := &model.ParticipantMovement{
-			MovementId:    m.Id,
-			ParticipantId: participantMovement.ParticipantId,
-			Amount:        participantMovement.Amount,
+func saveTransferMovement(ctx context.Context, group model.Group, transferMovement model.TransferMovement) (*model.Movement, []*model.ParticipantMovement, error) {
+	err := model.EnsureTransferIsValid(transferMovement)
+	if err != nil {
+		return nil, nil, err
+	}
+	return saveMovement(ctx, group, &transferMovement.Movement, model.BuildParticipantsTransferMovements(transferMovement))
+}
+
+// same as saveTransferMovement but through the repositories of a transaction in progress
+func saveTransferMovementIn(repos repositories.Repositories, group model.Group, transferMovement model.TransferMovement) (*model.Movement, []*model.ParticipantMovement, error) {
+	err := model.EnsureTransferIsValid(transferMovement)
+	if err != nil {
+		return nil, nil, err
+	}
+	return saveMovementIn(repos, group, &transferMovement.Movement, model.BuildParticipantsTransferMovements(transferMovement))
+}
+
+// Takes the rate to convert the movement's currency into the group's one at the time the movement is entered, so later rate changes don't alter past balances
+func snapshotExchangeRate(group model.Group, currency model.Currency, givenRate float64, at time.Time) (float64, error) {
+	err := model.EnsureCurrencyIsValid(currency)
+	if err != nil {
+		return 0, err
+	}
+	if currency == group.Currency {
+		return 1, nil
+	}
+	if givenRate != 0 {
+		return givenRate, model.EnsureExchangeRateIsValid(givenRate)
+	}
+	if exchangeRateProvider == nil {
+		return 0, model.ErrExchangeRateNotFound
+	}
+	return exchangeRateProvider.GetRate(currency, group.Currency, at)
+}
+
+// a zero value without currency (e.g. a consumed amount not given) is taken as expressed in the given currency
+func ensureMoniesAreInCurrency(currency model.Currency, monies ...model.Money) error {
+	for _, money := range monies {
+		if money.Currency != currency && !(money.IsZero() && money.Currency == "") {
+			return model.ErrCurrencyMismatch
+		}
+	}
+	return nil
+}
+
+func ensureParticipantsBelongToGroup(repos repositories.Repositories, groupId int, participantIds []int) error {
+	for _, participantId := range participantIds {
+		participant, err := repos.Participants.GetById(participantId)
+		if err != nil {
+			return err
+		}
+		if participant.GroupId != groupId {
+			return model.ErrParticipantNotInGroup
+		}
+	}
+	return nil
+}
+
+func saveMovement(ctx context.Context, group model.Group, m *model.Movement, participantMovements []model.ParticipantMovement) (*model.Movement, []*model.ParticipantMovement, error) {
+	var saved *model.Movement
+	var pms []*model.ParticipantMovement
+	err := repositories.RunInTransaction(storage.WithContext(ctx), func(repos repositories.Repositories) error { // a movement without all its participants would corrupt the balances
+		// every attempt saves its own copies, as a rolled back one may have already set their ids
+		movement := *m
+		attemptParticipantMovements := append([]model.ParticipantMovement(nil), participantMovements...)
+		attemptSaved, attemptPms, err := saveMovementIn(repos, group, &movement, attemptParticipantMovements)
+		if err != nil {
+			return err
 		}
-		pm, err = participantMovementsRepository.Save(pm)
+		saved, pms = attemptSaved, attemptPms
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return saved, pms, nil
+}
+
+// saves the movement along with its participants through the repositories of a transaction in progress
+func saveMovementIn(repos repositories.Repositories, group model.Group, m *model.Movement, participantMovements []model.ParticipantMovement) (*model.Movement, []*model.ParticipantMovement, error) {
+	_, err := buildParticipantsShare(group, *m, participantMovements) // rejects the movement before persisting it if can not be split
+	if err != nil {
+		return nil, nil, err
+	}
+
+	saved, err := repos.Movements.Save(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	pms := make([]*model.ParticipantMovement, 0, len(participantMovements))
+	for i := range participantMovements {
+		pm := &participantMovements[i]
+		pm.MovementId = saved.Id
+		pm, err = repos.ParticipantMovements.Save(pm)
 		if err != nil {
-			// TODO: must rollback   movementsRepository.Save(m), in memory repository shall have a transcation mechanishm
 			return nil, nil, err
 		}
 		pms = append(pms, pm)
 	}
-	return m, pms, nil
+	return saved, pms, nil
 }
 
-func CalculateBalances(groupId int) (model.DebitCreditMap, model.ParticipantShareByParticipantId, error) {
-	_, err := groupsRepository.GetById(groupId)
+// The amount of each participant (e.g. their share) in the group's currency
+type MoneyByParticipantId map[int]model.Money
+
+// What each debtor owes to each creditor (see model.DebitCreditMap) in the group's currency
+type DebitCreditMap map[int]map[int]model.Money
+
+// Balances of the group expressed in its base currency, each movement is converted with the exchange rate taken when it was entered.
+// Every installment counts, even the ones not yet due.
+func CalculateBalances(ctx context.Context, groupId int) (DebitCreditMap, MoneyByParticipantId, error) {
+	return CalculateBalancesAsOf(ctx, groupId, time.Time{})
+}
+
+// Same as CalculateBalances but only with what is owed as of the given moment: movements entered later are left out and
+// installments movements only count the installments already due. A zero asOf counts everything.
+func CalculateBalancesAsOf(ctx context.Context, groupId int, asOf time.Time) (DebitCreditMap, MoneyByParticipantId, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(groupId)
+	if err != nil {
+		return nil, nil, err
+	}
+	balance, shares, err := calculateBalancesAsOf(repos, *group, asOf)
 	if err != nil {
 		return nil, nil, err
 	}
+	return DebitCreditMap(balance), MoneyByParticipantId(shares), nil
+}
 
-	movements, err := movementsRepository.GetByGroupId(groupId)
+func calculateBalancesAsOf(repos repositories.Repositories, group model.Group, asOf time.Time) (model.DebitCreditMap, model.ParticipantShareByParticipantId, error) {
+	movementsShares, err := buildMovementsShares(repos, group)
 	if err != nil {
 		return nil, nil, err
 	}
+	if !asOf.IsZero() {
+		movementsShares, err = filterMovementsSharesAsOf(movementsShares, asOf)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	acumulatedBalance := make(model.DebitCreditMap)
 	acumulatedShare := make(model.ParticipantShareByParticipantId)
-	for _, movement := range movements {
-		participantMovementsPtr, err := participantMovementsRepository.GetByMovementId(movement.Id)
+	for _, movementShares := range movementsShares {
+		acumulatedShare, err = model.SumParticipantShares(acumulatedShare, movementShares.shares)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		participantMovements := util.ToValues(participantMovementsPtr)
-		model.EnsureMovementAmountMatchesParticipantAmounts(*movement, participantMovements)
+		balance, err := model.BuildProportionalDebitCreditMap(movementShares.shares)
 		if err != nil {
 			return nil, nil, err
 		}
-		participantShareByParticipantId := model.BuildParticipantsEqualShare(*movement, participantMovements)
-		err = model.EnsureSharesSumToZero(participantShareByParticipantId)
+		acumulatedBalance, err = model.SumDebitCreditMaps(acumulatedBalance, balance)
 		if err != nil {
 			return nil, nil, err
 		}
-		acumulatedShare = model.SumParticipantShares(acumulatedShare, participantShareByParticipantId)
+	}
+	netBalance, err := model.NetDebitCreditMap(acumulatedBalance)
+	if err != nil {
+		return nil, nil, err
+	}
+	return netBalance, acumulatedShare, nil
+}
 
-		balance := model.BuildDebitCreditMap(participantMovements, participantShareByParticipantId)
-		acumulatedBalance = model.SumDebitCreditMaps(acumulatedBalance, balance)
+type Balances struct {
+	Nominal  MoneyByParticipantId `json:"nominal"`
+	Adjusted MoneyByParticipantId `json:"adjusted"` // same as the nominal ones unless the group is inflation adjusted
+	AsOf     int64                `json:"asOf"`     // unix timestamp, in seconds since epoch
+}
+
+// Balances of the group as of the given moment (see CalculateBalancesAsOf) both nominal and, for inflation adjusted groups, with the
+// shares of each movement scaled by the price index variation between the month the movement was entered and the month of asOf.
+// A zero asOf counts everything and adjusts it to the current month.
+func CalculateInflationAdjustedBalances(ctx context.Context, groupId int, asOf time.Time) (*Balances, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.InflationAdjusted && priceIndexProvider == nil { // the group was adjusted by a server that had a price index
+		return nil, model.ErrPriceIndexNotConfigured
 	}
-	return acumulatedBalance, acumulatedShare, nil
+
+	movementsShares, err := buildMovementsShares(repos, *group)
+	if err != nil {
+		return nil, err
+	}
+	if asOf.IsZero() {
+		asOf = time.Now()
+	} else {
+		movementsShares, err = filterMovementsSharesAsOf(movementsShares, asOf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nominalShare := make(model.ParticipantShareByParticipantId)
+	adjustedShare := make(model.ParticipantShareByParticipantId)
+	for _, movementShares := range movementsShares {
+		nominalShare, err = model.SumParticipantShares(nominalShare, movementShares.shares)
+		if err != nil {
+			return nil, err
+		}
+		shares := movementShares.shares
+		if group.InflationAdjusted {
+			factor, err := model.BuildInflationAdjustmentFactor(priceIndexProvider, time.Unix(movementShares.movement.CreatedAt, 0), asOf)
+			if err != nil {
+				return nil, err
+			}
+			shares, err = model.AdjustShares(shares, factor)
+			if err != nil {
+				return nil, err
+			}
+		}
+		adjustedShare, err = model.SumParticipantShares(adjustedShare, shares)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Balances{
+		Nominal:  MoneyByParticipantId(nominalShare),
+		Adjusted: MoneyByParticipantId(adjustedShare),
+		AsOf:     asOf.Unix(),
+	}, nil
 }
 
+// Gathers per participant totals (paid, consumed, sent, received, credit and debt) in the group's currency along all the group's
+// movements, sorted by participant id
+func CalculateBalanceSheet(ctx context.Context, groupId int) ([]model.ParticipantBalance, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(groupId)
+	if err != nil {
+		return nil, err
+	}
 
-func CalculateBalance(groupId int, movementId int) (model.DebitCreditMap, model.ParticipantShareByParticipantId, error) {
-	_, err := groupsRepository.GetById(groupId)
+	movementsShares, err := buildMovementsShares(repos, *group)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	balanceSheet := model.NewParticipantsBalanceSheet()
+	for _, movementShares := range movementsShares {
+		err = balanceSheet.Add(movementShares.movement, movementShares.participantMovements, movementShares.shares)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return balanceSheet.GetBalances(), nil
+}
+
+type movementShares struct {
+	movement             model.Movement
+	participantMovements []model.ParticipantMovement
+	shares               model.ParticipantShareByParticipantId
+}
+
+func buildMovementsShares(repos repositories.Repositories, group model.Group) ([]movementShares, error) {
+	movements, err := repos.Movements.GetByGroupId(group.Id)
+	if err != nil {
+		return nil, err
 	}
 
-	movement, err := movementsRepository.GetById(movementId)
+	movementsShares := make([]movementShares, 0, len(movements))
+	for _, movement := range movements {
+		participantMovementsPtr, err := repos.ParticipantMovements.GetByMovementId(movement.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		convertedMovement, participantMovements, err := model.ConvertMovementToCurrency(*movement, util.ToValues(participantMovementsPtr), group.Currency)
+		if err != nil {
+			return nil, err
+		}
+		participantShareByParticipantId, err := buildParticipantsShare(group, convertedMovement, participantMovements)
+		if err != nil {
+			return nil, err
+		}
+		movementsShares = append(movementsShares, movementShares{
+			movement:             convertedMovement,
+			participantMovements: participantMovements,
+			shares:               participantShareByParticipantId,
+		})
+	}
+	return movementsShares, nil
+}
+
+func filterMovementsSharesAsOf(movementsShares []movementShares, asOf time.Time) ([]movementShares, error) {
+	filtered := make([]movementShares, 0, len(movementsShares))
+	for _, movementShares := range movementsShares {
+		if movementShares.movement.CreatedAt > asOf.Unix() {
+			continue
+		}
+		if movementShares.movement.Kind == model.InstallmentsKind {
+			var err error
+			movementShares.shares, err = model.BuildDueInstallmentsShares(movementShares.movement, movementShares.shares, asOf)
+			if err != nil {
+				return nil, err
+			}
+		}
+		filtered = append(filtered, movementShares)
+	}
+	return filtered, nil
+}
+
+// Suggests the transfers, in the group's currency, that would leave every participant of the group even, when the group has
+// settlement units the members even up with their unit's representative and the representatives settle the units among them
+func CalculateSettlement(ctx context.Context, groupId int) ([]model.SettlementTransfer, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(groupId)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	return calculateSettlement(repos, *group)
+}
+
+func calculateSettlement(repos repositories.Repositories, group model.Group) ([]model.SettlementTransfer, error) {
+	shares, err := calculateShares(repos, group)
+	if err != nil {
+		return nil, err
+	}
+	return buildSettlementTransfers(group, shares)
+}
+
+func buildSettlementTransfers(group model.Group, shares model.ParticipantShareByParticipantId) ([]model.SettlementTransfer, error) {
+	if len(group.SettlementUnits) == 0 {
+		return model.BuildSettlementTransfers(shares)
+	}
+	settlement, err := model.BuildUnitsSettlement(shares, group.SettlementUnits)
+	if err != nil {
+		return nil, err
+	}
+	return settlement.BuildParticipantsTransfers(), nil
+}
 
-	participantMovementsPtr, err := participantMovementsRepository.GetByMovementId(movement.Id)
+// The aggregated share of a settlement unit along with the share of each of its members (see model.SettlementUnitBalance) in the
+// group's currency
+type SettlementUnitBalance struct {
+	model.SettlementUnit
+	Share  model.Money          `json:"share"`
+	Shares MoneyByParticipantId `json:"shares"`
+}
+
+type UnitsSettlement struct {
+	Units     []SettlementUnitBalance    `json:"units"`     // sorted by representative id
+	Transfers []model.SettlementTransfer `json:"transfers"` // between the units' representatives
+}
+
+func newUnitsSettlement(settlement model.UnitsSettlement) UnitsSettlement {
+	units := make([]SettlementUnitBalance, 0, len(settlement.Units))
+	for _, unit := range settlement.Units {
+		units = append(units, SettlementUnitBalance{
+			SettlementUnit: unit.SettlementUnit,
+			Share:          unit.Share,
+			Shares:         MoneyByParticipantId(unit.Shares),
+		})
+	}
+	return UnitsSettlement{Units: units, Transfers: settlement.Transfers}
+}
+
+// Suggests the transfers that would leave every settlement unit of the group even, along with each unit's and member's share
+func CalculateUnitsSettlement(ctx context.Context, groupId int) (*UnitsSettlement, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(groupId)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := calculateShares(repos, *group)
+	if err != nil {
+		return nil, err
+	}
+	settlement, err := model.BuildUnitsSettlement(shares, group.SettlementUnits)
+	if err != nil {
+		return nil, err
+	}
+	unitsSettlement := newUnitsSettlement(settlement)
+	return &unitsSettlement, nil
+}
+
+const settlementConcept = "Settlement"
+
+// Records every suggested settlement transfer as a transfer movement, either all of them are recorded and every participant of the
+// group is even afterwards or none is
+func SettleUp(ctx context.Context, groupId int) ([]*model.Movement, error) {
+	var movements []*model.Movement
+	err := repositories.RunInTransaction(storage.WithContext(ctx), func(repos repositories.Repositories) error { // a partially settled group would suggest a different settlement
+		group, err := repos.Groups.GetById(groupId)
+		if err != nil {
+			return err
+		}
+		shares, err := calculateShares(repos, *group)
+		if err != nil {
+			return err
+		}
+		transfers, err := buildSettlementTransfers(*group, shares)
+		if err != nil {
+			return err
+		}
+		movements = make([]*model.Movement, 0, len(transfers))
+		for _, transfer := range transfers {
+			m, _, err := saveTransferMovementIn(repos, *group, buildSettlementTransferMovement(*group, transfer))
+			if err != nil {
+				return err
+			}
+			movements = append(movements, m)
+		}
+
+		shares, err = calculateShares(repos, *group)
+		if err != nil {
+			return err
+		}
+		return model.EnsureSharesAreSettled(shares)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+// the accumulated shares of every movement of the group
+func calculateShares(repos repositories.Repositories, group model.Group) (model.ParticipantShareByParticipantId, error) {
+	movementsShares, err := buildMovementsShares(repos, group)
+	if err != nil {
+		return nil, err
+	}
+	shares := make(model.ParticipantShareByParticipantId)
+	for _, movementShares := range movementsShares {
+		shares, err = model.SumParticipantShares(shares, movementShares.shares)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return shares, nil
+}
+
+// Records that a debtor paid a suggested settlement transfer, either completely or only part of the suggested amount, which must
+// be given in the group's currency as the suggested transfers are
+func SettleUpPartially(ctx context.Context, groupId int, transfer model.SettlementTransfer) (*model.Movement, error) {
+	var movement *model.Movement
+	err := repositories.RunInTransaction(storage.WithContext(ctx), func(repos repositories.Repositories) error { // the transfer must still be suggested when it is recorded
+		group, err := repos.Groups.GetById(groupId)
+		if err != nil {
+			return err
+		}
+		suggestedTransfers, err := calculateSettlement(repos, *group)
+		if err != nil {
+			return err
+		}
+		err = model.EnsureSettlementTransferIsSuggested(transfer, suggestedTransfers)
+		if err != nil {
+			return err
+		}
+		movement, _, err = saveTransferMovementIn(repos, *group, buildSettlementTransferMovement(*group, transfer))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func buildSettlementTransferMovement(group model.Group, transfer model.SettlementTransfer) model.TransferMovement {
+	movement := model.Movement{
+		GroupId:      group.Id,
+		ExchangeRate: 1,
+		CreatedAt:    time.Now().Unix(),
+		Concept:      settlementConcept,
+	}
+	return model.BuildSettlementTransferMovement(transfer, movement)
+}
+
+// The balance of a single movement of the group, in the group's currency
+func CalculateBalance(ctx context.Context, groupId int, movementId int) (DebitCreditMap, MoneyByParticipantId, error) {
+	repos := storage.WithContext(ctx).Repositories()
+	group, err := repos.Groups.GetById(groupId)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	participantMovements := util.ToValues(participantMovementsPtr)
+	movement, err := repos.Movements.GetById(movementId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if movement.GroupId != group.Id { // as if it did not exist, its group's currency and participants are the ones that apply
+		return nil, nil, repositories.EntityNotExistsErr
+	}
 
-	err = model.EnsureMovementAmountMatchesParticipantAmounts(*movement, participantMovements)
+	participantMovementsPtr, err := repos.ParticipantMovements.GetByMovementId(movement.Id)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	shares := model.BuildParticipantsEqualShare(*movement, participantMovements)
-	err = model.EnsureSharesSumToZero(shares)
+	convertedMovement, participantMovements, err := model.ConvertMovementToCurrency(*movement, util.ToValues(participantMovementsPtr), group.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	shares, err := buildParticipantsShare(*group, convertedMovement, participantMovements)
 	if err != nil {
 		return nil, nil, err
 	}
-	/*a, _ := json.Marshal(*movement)
-	b, _ := json.Marshal(participantMovements)
-	c, _ := json.Marshal(shares)
-	fmt.Println(string(a), "\n", string(b), "\nShares:", string(c))*/
-	balance := model.BuildDebitCreditMap(participantMovements, shares)
-	return balance, shares, nil
+	balance, err := model.BuildProportionalDebitCreditMap(shares)
+	if err != nil {
+		return nil, nil, err
+	}
+	return DebitCreditMap(balance), MoneyByParticipantId(shares), nil
 }
 
+// builds the participants' shares of a movement according to its kind (honouring the split strategy and the group's remainder policy for expenses),
+// checking the model invariants before and after
+func buildParticipantsShare(group model.Group, movement model.Movement, participantMovements []model.ParticipantMovement) (model.ParticipantShareByParticipantId, error) {
+	err := model.EnsureMovementAmountMatchesParticipantAmounts(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+	var shares model.ParticipantShareByParticipantId
+	switch movement.Kind {
+	case "", model.ExpenseKind:
+		shares, err = model.BuildParticipantsShare(movement, participantMovements, group.RemainderPolicy)
+	case model.InstallmentsKind:
+		err = model.EnsureInstallmentsAreValid(movement)
+		if err == nil {
+			shares, err = model.BuildParticipantsShare(movement, participantMovements, group.RemainderPolicy)
+		}
+	case model.TransferKind:
+		var transfer model.TransferMovement
+		transfer, err = model.BuildTransferMovement(movement, participantMovements)
+		if err == nil {
+			shares = model.BuildParticipantsTransferShare(transfer)
+		}
+	default:
+		err = model.ErrUnknownMovementKind
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = model.EnsureSharesSumToZero(shares)
+	if err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
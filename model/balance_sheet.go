@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// per participant totals along several movements, all of them in the currency of the movements
+type ParticipantBalance struct {
+	ParticipantId int   `json:"participantId"`
+	Paid          Money `json:"paid"`     // lo que puso en los gastos
+	Consumed      Money `json:"consumed"` // lo que le corresponde pagar de los gastos (lo que puso menos su parte)
+	Sent          Money `json:"sent"`     // lo que transfirió a otros participantes
+	Received      Money `json:"received"` // lo que otros participantes le transfirieron
+	Credit        Money `json:"credit"`   // la suma de las partes a favor
+	Debt          Money `json:"debt"`     // la suma de las partes en contra
+}
+
+func newParticipantBalance(participantId int, currency Currency) *ParticipantBalance {
+	zero := NewMoney(0, currency)
+	return &ParticipantBalance{ParticipantId: participantId, Paid: zero, Consumed: zero, Sent: zero, Received: zero, Credit: zero, Debt: zero}
+}
+
+var ErrParticipantNotInBalanceSheet error = errors.New("The participant has no movements in the balance sheet")
+
+// Implements BalanceSheet accumulating the participants' movements one by one, every movement must be in the same currency
+type ParticipantsBalanceSheet struct {
+	balanceByParticipantId map[int]*ParticipantBalance
+	currency               Currency // the one of the movements added so far
+}
+
+var _ BalanceSheet = (*ParticipantsBalanceSheet)(nil)
+
+func NewParticipantsBalanceSheet() *ParticipantsBalanceSheet {
+	return &ParticipantsBalanceSheet{balanceByParticipantId: make(map[int]*ParticipantBalance)}
+}
+
+// Adds a movement given by its participant movements and the shares built from them, the transfers count as sent and received
+// instead of paid and consumed as they settle debts rather than being expenses. A movement in another currency than the ones
+// already added (or whose shares are in another currency) is rejected with ErrCurrencyMismatch.
+func (sheet *ParticipantsBalanceSheet) Add(movement Movement, participantMovements []ParticipantMovement, shares ParticipantShareByParticipantId) error {
+	sharesCurrency, err := shares.getCurrency()
+	if err != nil {
+		return err
+	}
+	if (len(shares) > 0 && sharesCurrency != movement.Currency) || (len(sheet.balanceByParticipantId) > 0 && sheet.currency != movement.Currency) {
+		return ErrCurrencyMismatch
+	}
+	for _, participantMovement := range participantMovements {
+		sheet.currency = movement.Currency
+		balance, exists := sheet.balanceByParticipantId[participantMovement.ParticipantId]
+		if !exists {
+			balance = newParticipantBalance(participantMovement.ParticipantId, movement.Currency)
+			sheet.balanceByParticipantId[participantMovement.ParticipantId] = balance
+		}
+		share := shares[participantMovement.ParticipantId].Amount
+		if movement.Kind == TransferKind {
+			balance.Sent.Amount += participantMovement.Amount
+			balance.Received.Amount += participantMovement.Amount - share
+		} else {
+			balance.Paid.Amount += participantMovement.Amount
+			balance.Consumed.Amount += participantMovement.Amount - share
+		}
+		if share > 0 {
+			balance.Credit.Amount += share
+		} else {
+			balance.Debt.Amount -= share
+		}
+	}
+	return nil
+}
+
+func (sheet *ParticipantsBalanceSheet) GetBalance(participantId int) (ParticipantBalance, error) {
+	balance, exists := sheet.balanceByParticipantId[participantId]
+	if !exists {
+		return ParticipantBalance{}, ErrParticipantNotInBalanceSheet
+	}
+	return *balance, nil
+}
+
+func (sheet *ParticipantsBalanceSheet) GetCredit(participantId int) (Money, error) {
+	balance, err := sheet.GetBalance(participantId)
+	return balance.Credit, err
+}
+
+func (sheet *ParticipantsBalanceSheet) GetDebt(participantId int) (Money, error) {
+	balance, err := sheet.GetBalance(participantId)
+	return balance.Debt, err
+}
+
+func (sheet *ParticipantsBalanceSheet) GetPaid(participantId int) (Money, error) {
+	balance, err := sheet.GetBalance(participantId)
+	return balance.Paid, err
+}
+
+func (sheet *ParticipantsBalanceSheet) GetConsumed(participantId int) (Money, error) {
+	balance, err := sheet.GetBalance(participantId)
+	return balance.Consumed, err
+}
+
+// the balances sorted by participant id
+func (sheet *ParticipantsBalanceSheet) GetBalances() []ParticipantBalance {
+	balances := make([]ParticipantBalance, 0, len(sheet.balanceByParticipantId))
+	for _, balance := range sheet.balanceByParticipantId {
+		balances = append(balances, *balance)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].ParticipantId < balances[j].ParticipantId
+	})
+	return balances
+}
+
+func (sheet *ParticipantsBalanceSheet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sheet.GetBalances())
+}
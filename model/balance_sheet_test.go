@@ -0,0 +1,62 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParticipantsBalanceSheet(t *testing.T) {
+	balanceSheet := NewParticipantsBalanceSheet()
+
+	dinner := Movement{Id: 1, Amount: 900, Currency: "ARS"}
+	dinnerParticipantMovements := []ParticipantMovement{
+		{ParticipantId: 1, MovementId: 1, Amount: 900},
+		{ParticipantId: 2, MovementId: 1, Amount: 0},
+		{ParticipantId: 3, MovementId: 1, Amount: 0},
+	}
+	err := balanceSheet.Add(dinner, dinnerParticipantMovements, BuildParticipantsEqualShare(dinner, dinnerParticipantMovements))
+	if err != nil {
+		t.Fatalf("unexpected error adding the dinner: %v", err)
+	}
+
+	transfer := TransferMovement{Movement: Movement{Id: 2, Amount: 300, Currency: "ARS", Kind: TransferKind}, FromParticipantId: 2, ToParticipantId: 1}
+	err = balanceSheet.Add(transfer.Movement, BuildParticipantsTransferMovements(transfer), BuildParticipantsTransferShare(transfer))
+	if err != nil {
+		t.Fatalf("unexpected error adding the transfer: %v", err)
+	}
+
+	expected := []ParticipantBalance{
+		{ParticipantId: 1, Paid: ars(900), Consumed: ars(300), Sent: ars(0), Received: ars(300), Credit: ars(600), Debt: ars(300)},
+		{ParticipantId: 2, Paid: ars(0), Consumed: ars(300), Sent: ars(300), Received: ars(0), Credit: ars(300), Debt: ars(300)},
+		{ParticipantId: 3, Paid: ars(0), Consumed: ars(300), Sent: ars(0), Received: ars(0), Credit: ars(0), Debt: ars(300)},
+	}
+	if !reflect.DeepEqual(balanceSheet.GetBalances(), expected) {
+		t.Fatalf("generated %v, expected %v", balanceSheet.GetBalances(), expected)
+	}
+
+	credit, err := balanceSheet.GetCredit(1)
+	if err != nil || credit != ars(600) {
+		t.Errorf("generated credit %v (error %v), expected credit 600", credit, err)
+	}
+	debt, err := balanceSheet.GetDebt(3)
+	if err != nil || debt != ars(300) {
+		t.Errorf("generated debt %v (error %v), expected debt 300", debt, err)
+	}
+	_, err = balanceSheet.GetDebt(4)
+	if err != ErrParticipantNotInBalanceSheet {
+		t.Errorf("generated error %v, expected error %v", err, ErrParticipantNotInBalanceSheet)
+	}
+
+	lunch := Movement{Id: 3, Amount: 10, Currency: "USD"}
+	lunchParticipantMovements := []ParticipantMovement{
+		{ParticipantId: 1, MovementId: 3, Amount: 10},
+		{ParticipantId: 2, MovementId: 3, Amount: 0},
+	}
+	err = balanceSheet.Add(lunch, lunchParticipantMovements, BuildParticipantsEqualShare(lunch, lunchParticipantMovements))
+	if err != ErrCurrencyMismatch {
+		t.Errorf("generated error %v, expected error %v", err, ErrCurrencyMismatch)
+	}
+	if !reflect.DeepEqual(balanceSheet.GetBalances(), expected) {
+		t.Errorf("the rejected movement changed the balances to %v, expected %v", balanceSheet.GetBalances(), expected)
+	}
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Gives how many units of a currency one unit of another currency is worth at a given moment (e.g. 1 USD = 1050.5 ARS)
+type ExchangeRateProvider interface {
+	GetRate(from Currency, to Currency, at time.Time) (float64, error)
+}
+
+var ErrExchangeRateNotFound error = errors.New("There is no exchange rate between the given currencies")
+var ErrInvalidExchangeRate error = errors.New("The exchange rate must be greater than zero")
+
+func EnsureExchangeRateIsValid(rate float64) error {
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return ErrInvalidExchangeRate
+	}
+	return nil
+}
+
+// Converts the money into the given currency using the rate of one unit of money's currency expressed in units of the target currency,
+// rounding to the nearest minor unit of the target currency
+func (money Money) ConvertTo(currency Currency, rate float64) Money {
+	if money.Currency == currency {
+		return money
+	}
+	digitsDifference := currency.MinorUnitDigits() - money.Currency.MinorUnitDigits()
+	converted := float64(money.Amount) * rate * math.Pow10(digitsDifference)
+	return NewMoney(Price(math.Round(converted)), currency)
+}
+
+// Expresses a movement (and its participants' amounts) in the given currency using the exchange rate snapshot taken when the movement was entered.
+// The participants' amounts (as well as the surcharges and items) are distributed in proportion to the original ones so the movement's
+// invariants still hold after rounding.
+func ConvertMovementToCurrency(movement Movement, participantMovements []ParticipantMovement, currency Currency) (Movement, []ParticipantMovement, error) {
+	if movement.Currency == "" { // entered before movements had a currency, so it is already in the group's one
+		movement.Currency = currency
+	}
+	if movement.Currency == currency {
+		return movement, participantMovements, nil
+	}
+	err := EnsureExchangeRateIsValid(movement.ExchangeRate)
+	if err != nil {
+		return Movement{}, nil, err
+	}
+
+	converted := movement
+	converted.Amount = movement.GetAmount().ConvertTo(currency, movement.ExchangeRate).Amount
+	converted.Currency = currency
+	converted.ExchangeRate = 1
+	sortRemainderRecipients, _ := LargestRemainderPolicy.remainderRecipientsSorter(movement, participantMovements)
+
+	subtotal := movement.GetSubtotal()
+	amounts := []Price{subtotal} // the subtotal followed by the surcharges
+	for _, surcharge := range movement.Surcharges {
+		amounts = append(amounts, surcharge.Amount)
+	}
+	convertedAmounts, err := spreadConvertedAmount(amounts, converted.Amount, sortRemainderRecipients)
+	if err != nil {
+		return Movement{}, nil, err
+	}
+	convertedSubtotal := convertedAmounts[0]
+	if len(movement.Surcharges) > 0 {
+		converted.Surcharges = make([]Surcharge, 0, len(movement.Surcharges))
+		for i, surcharge := range movement.Surcharges {
+			surcharge.Amount = convertedAmounts[i+1]
+			converted.Surcharges = append(converted.Surcharges, surcharge)
+		}
+	}
+	if len(movement.ShareRules) > 0 {
+		converted.ShareRules = make([]ShareRule, 0, len(movement.ShareRules))
+		for _, rule := range movement.ShareRules {
+			rule.Cap = NewMoney(rule.Cap, movement.Currency).ConvertTo(currency, movement.ExchangeRate).Amount
+			converted.ShareRules = append(converted.ShareRules, rule)
+		}
+	}
+	if len(movement.Items) > 0 {
+		amounts = make([]Price, 0, len(movement.Items))
+		for _, item := range movement.Items {
+			amounts = append(amounts, item.Amount)
+		}
+		convertedAmounts, err = spreadConvertedAmount(amounts, convertedSubtotal, sortRemainderRecipients)
+		if err != nil {
+			return Movement{}, nil, err
+		}
+		converted.Items = make([]MovementItem, 0, len(movement.Items))
+		for i, item := range movement.Items {
+			item.Amount = convertedAmounts[i]
+			converted.Items = append(converted.Items, item)
+		}
+	}
+
+	paidAmounts := make([]Price, 0, len(participantMovements))
+	consumedAmounts := make([]Price, 0, len(participantMovements))
+	totalConsumed := 0
+	for _, participantMovement := range participantMovements {
+		paidAmounts = append(paidAmounts, participantMovement.Amount)
+		consumedAmounts = append(consumedAmounts, participantMovement.Consumed)
+		totalConsumed += participantMovement.Consumed
+	}
+	convertedPaidAmounts, err := spreadConvertedAmount(paidAmounts, converted.Amount, sortRemainderRecipients)
+	if err != nil {
+		return Movement{}, nil, err
+	}
+	convertedTotalConsumed := NewMoney(totalConsumed, movement.Currency).ConvertTo(currency, movement.ExchangeRate).Amount
+	if totalConsumed == subtotal {
+		convertedTotalConsumed = convertedSubtotal // keeps the exact split invariant
+	}
+	convertedConsumedAmounts, err := spreadConvertedAmount(consumedAmounts, convertedTotalConsumed, sortRemainderRecipients)
+	if err != nil {
+		return Movement{}, nil, err
+	}
+
+	convertedParticipantMovements := make([]ParticipantMovement, 0, len(participantMovements))
+	for i, participantMovement := range participantMovements {
+		participantMovement.Amount = convertedPaidAmounts[i]
+		participantMovement.Consumed = convertedConsumedAmounts[i]
+		convertedParticipantMovements = append(convertedParticipantMovements, participantMovement)
+	}
+	return converted, convertedParticipantMovements, nil
+}
+
+// spreads the converted amount in proportion to the original amounts, so the converted ones still add up to it
+func spreadConvertedAmount(amounts []Price, convertedAmount Price, sortRemainderRecipients remainderRecipientsSorter) ([]Price, error) {
+	convertedAmounts := make([]Price, len(amounts))
+	if convertedAmount == 0 {
+		return convertedAmounts, nil
+	}
+	amountByIndex := make(map[int]int)
+	for i, amount := range amounts {
+		amountByIndex[i] = amount
+	}
+	convertedAmountByIndex, err := distributeProportionally(convertedAmount, amountByIndex, sortRemainderRecipients)
+	if err != nil {
+		return nil, err
+	}
+	for i := range amounts {
+		convertedAmounts[i] = convertedAmountByIndex[i]
+	}
+	return convertedAmounts, nil
+}
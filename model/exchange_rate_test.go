@@ -0,0 +1,76 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMoneyConvertTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		money    Money
+		currency Currency
+		rate     float64
+		expected Money
+	}{
+		{name: "Same currency is left untouched", money: NewMoney(1234, "ARS"), currency: "ARS", rate: 3, expected: NewMoney(1234, "ARS")},
+		{name: "Both with cents", money: NewMoney(1050, "USD"), currency: "ARS", rate: 1050.5, expected: NewMoney(1103025, "ARS")},
+		{name: "Rounds to the nearest minor unit", money: NewMoney(100000, "ARS"), currency: "USD", rate: 1 / 1050.5, expected: NewMoney(95, "USD")},
+		{name: "Target currency without minor units", money: NewMoney(1000, "USD"), currency: "JPY", rate: 150, expected: NewMoney(1500, "JPY")},
+		{name: "Source currency without minor units", money: NewMoney(1500, "JPY"), currency: "USD", rate: 0.0067, expected: NewMoney(1005, "USD")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converted := test.money.ConvertTo(test.currency, test.rate)
+			if converted != test.expected {
+				t.Errorf("converted %v, expected %v", converted, test.expected)
+			}
+		})
+	}
+}
+
+func TestConvertMovementToCurrencyKeepsInvariants(t *testing.T) {
+	movement := Movement{Id: 1, Amount: 1000, Currency: "USD", ExchangeRate: 0.333, SplitStrategy: ExactSplit}
+	participantMovements := []ParticipantMovement{
+		{ParticipantId: 1, Amount: 500, Consumed: 333},
+		{ParticipantId: 2, Amount: 250, Consumed: 333},
+		{ParticipantId: 3, Amount: 250, Consumed: 334},
+	}
+
+	converted, convertedParticipantMovements, err := ConvertMovementToCurrency(movement, participantMovements, "EUR")
+	if err != nil {
+		t.Fatalf("Failed to convert movement: %v", err)
+	}
+	if converted.Amount != 333 || converted.Currency != "EUR" {
+		t.Errorf("converted movement amount %v, expected 3.33 EUR", converted.GetAmount())
+	}
+	err = EnsureMovementAmountMatchesParticipantAmounts(converted, convertedParticipantMovements)
+	if err != nil {
+		t.Error(err)
+	}
+	err = EnsureMovementAmountMatchesParticipantConsumedAmounts(converted, convertedParticipantMovements)
+	if err != nil {
+		t.Error(err)
+	}
+	expectedAmounts := []Price{167, 83, 83}
+	expectedConsumed := []Price{111, 111, 111}
+	for i, participantMovement := range convertedParticipantMovements {
+		if participantMovement.Amount != expectedAmounts[i] || participantMovement.Consumed != expectedConsumed[i] {
+			t.Errorf("participant %d converted to amount %d and consumed %d, expected %d and %d", participantMovement.ParticipantId, participantMovement.Amount, participantMovement.Consumed, expectedAmounts[i], expectedConsumed[i])
+		}
+	}
+
+	_, _, err = ConvertMovementToCurrency(Movement{Amount: 1000, Currency: "USD"}, participantMovements, "EUR")
+	if err != ErrInvalidExchangeRate {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidExchangeRate)
+	}
+	sameCurrency, sameCurrencyParticipantMovements, _ := ConvertMovementToCurrency(movement, participantMovements, "USD")
+	if !reflect.DeepEqual(sameCurrency, movement) || !reflect.DeepEqual(sameCurrencyParticipantMovements, participantMovements) {
+		t.Errorf("a movement already in the currency must not change")
+	}
+	withoutCurrency, _, _ := ConvertMovementToCurrency(Movement{Amount: 1000}, participantMovements, "EUR")
+	if withoutCurrency.Amount != 1000 || withoutCurrency.Currency != "EUR" {
+		t.Errorf("a movement without currency converted to %v %s, expected it in the target currency as is", withoutCurrency.Amount, withoutCurrency.Currency)
+	}
+}
@@ -1,8 +1,16 @@
 package model
 
+import (
+	"errors"
+)
+
 type Group struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
+	Id                int              `json:"id"`
+	Name              string           `json:"name"`
+	RemainderPolicy   RemainderPolicy  `json:"remainderPolicy"`
+	Currency          Currency         `json:"currency"`          // base currency, every movement is converted into it when calculating balances
+	InflationAdjusted bool             `json:"inflationAdjusted"` // whether the outstanding shares are adjusted by a price index besides the nominal ones
+	SettlementUnits   []SettlementUnit `json:"settlementUnits,omitempty"`
 }
 
 func (group Group) GetId() int {
@@ -13,10 +21,14 @@ func (group *Group) SetId(id int) {
 	group.Id = id
 }
 
+var ErrParticipantNotInGroup error = errors.New("The participant does not belong to the group")
+
 type Participant struct {
-	Id      int    `json:"id"`
-	Name    string `json:"name"`
-	GroupId int    `json:"groupId"`
+	Id        int                `json:"id"`
+	Name      string             `json:"name"`
+	GroupId   int                `json:"groupId"`
+	Weight    int                `json:"weight"`              // default weight in equal splits (e.g. 2 for a couple), one when not given
+	Presences []PresenceInterval `json:"presences,omitempty"` // only meaningful for presence splits
 }
 
 func (participant Participant) GetId() int {
@@ -26,3 +38,10 @@ func (participant Participant) GetId() int {
 func (participant *Participant) SetId(id int) {
 	participant.Id = id
 }
+
+func (participant Participant) GetWeight() int {
+	if participant.Weight <= 0 {
+		return 1
+	}
+	return participant.Weight
+}
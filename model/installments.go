@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidInstallments error = errors.New("An installments movement must have at least one installment and a start date")
+
+func EnsureInstallmentsAreValid(movement Movement) error {
+	if movement.Installments < 1 || movement.StartsAt <= 0 {
+		return ErrInvalidInstallments
+	}
+	return nil
+}
+
+// How many installments became due as of the given moment, the i-th one (zero based) is due i months after the start date
+func CountDueInstallments(movement Movement, asOf time.Time) int {
+	startsAt := time.Unix(movement.StartsAt, 0)
+	dueInstallments := 0
+	for dueInstallments < movement.Installments && !addMonthsClampingDay(startsAt, dueInstallments).After(asOf) {
+		dueInstallments++
+	}
+	return dueInstallments
+}
+
+// Same as AddDate with the given months but a day that the target month lacks becomes its last day instead of overflowing into the
+// next month (e.g. january 31th plus one month is february 29th or 28th rather than march 2nd or 3rd)
+func addMonthsClampingDay(at time.Time, months int) time.Time {
+	year, month, day := at.Date()
+	firstDayOfTargetMonth := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, at.Location())
+	lastDayOfTargetMonth := firstDayOfTargetMonth.AddDate(0, 1, -1).Day()
+	if day > lastDayOfTargetMonth {
+		day = lastDayOfTargetMonth
+	}
+	return time.Date(firstDayOfTargetMonth.Year(), firstDayOfTargetMonth.Month(), day, at.Hour(), at.Minute(), at.Second(), at.Nanosecond(), at.Location())
+}
+
+// Amount of each installment, the units left over by the division go to the first installments (e.g. 1000 in 3 is 334, 333, 333)
+func BuildInstallmentAmounts(movement Movement) []Price {
+	amounts := make([]Price, movement.Installments)
+	for i := range amounts {
+		amounts[i] = movement.Amount / movement.Installments
+		if i < movement.Amount%movement.Installments {
+			amounts[i]++
+		}
+	}
+	return amounts
+}
+
+// Scales the shares of the whole installments movement down to the part that is due as of the given moment
+func BuildDueInstallmentsShares(movement Movement, shares ParticipantShareByParticipantId, asOf time.Time) (ParticipantShareByParticipantId, error) {
+	dueAmount := 0
+	for _, amount := range BuildInstallmentAmounts(movement)[:CountDueInstallments(movement, asOf)] {
+		dueAmount += amount
+	}
+	if dueAmount == movement.Amount {
+		return shares, nil
+	}
+	return scaleShares(shares, func(totalCredit Price) Price {
+		return (2*totalCredit*dueAmount + movement.Amount) / (2 * movement.Amount) // rounded to the nearest unit
+	})
+}
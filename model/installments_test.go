@@ -0,0 +1,69 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCountDueInstallments(t *testing.T) {
+	movement := Movement{Amount: 1000, Kind: InstallmentsKind, Installments: 3, StartsAt: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC).Unix()}
+	tests := []struct {
+		asOf     time.Time
+		expected int
+	}{
+		{asOf: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), expected: 0},
+		{asOf: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC), expected: 1},
+		{asOf: time.Date(2024, 2, 29, 11, 59, 59, 0, time.UTC), expected: 1},
+		{asOf: time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), expected: 2}, // january 31th plus one month is the last day of february
+		{asOf: time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC), expected: 2},
+		{asOf: time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC), expected: 3}, // back to the 31th when the month has it
+		{asOf: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), expected: 3},
+	}
+
+	for _, test := range tests {
+		dueInstallments := CountDueInstallments(movement, test.asOf)
+		if dueInstallments != test.expected {
+			t.Errorf("as of %v got %d due installments, expected %d", test.asOf, dueInstallments, test.expected)
+		}
+	}
+}
+
+func TestBuildInstallmentAmounts(t *testing.T) {
+	amounts := BuildInstallmentAmounts(Movement{Amount: 1000, Installments: 3})
+	expected := []Price{334, 333, 333}
+	if !reflect.DeepEqual(amounts, expected) {
+		t.Errorf("got %v, expected %v", amounts, expected)
+	}
+}
+
+func TestBuildDueInstallmentsShares(t *testing.T) {
+	movement := Movement{Amount: 1000, Kind: InstallmentsKind, Installments: 3, StartsAt: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC).Unix()}
+	shares := shareAmounts{1: 500, 2: -250, 3: -250}
+	tests := []struct {
+		name     string
+		asOf     time.Time
+		expected shareAmounts
+	}{
+		{name: "Nothing due yet", asOf: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), expected: shareAmounts{1: 0, 2: 0, 3: 0}},
+		{name: "First installment due", asOf: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), expected: shareAmounts{1: 167, 2: -84, 3: -83}},
+		{name: "Two installments due", asOf: time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC), expected: shareAmounts{1: 334, 2: -167, 3: -167}},
+		{name: "Every installment due", asOf: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), expected: shares},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dueShares, err := BuildDueInstallmentsShares(movement, shares.toShares(), test.asOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dueShares, test.expected.toShares()) {
+				t.Fatalf("got %v, expected %v", dueShares, test.expected)
+			}
+			err = EnsureSharesSumToZero(dueShares)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
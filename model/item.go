@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+)
+
+// a product is shared in equal parts by its participants while taxes and tips are spread over the products in proportion to their amounts,
+// an item without kind is a product
+type ItemKind string
+
+const (
+	ProductItem ItemKind = "product"
+	TaxItem     ItemKind = "tax"
+	TipItem     ItemKind = "tip"
+)
+
+// A line of an itemised receipt (e.g. a supermarket ticket), only meaningful for itemised splits
+type MovementItem struct {
+	Concept        string   `json:"concept"`
+	Amount         Price    `json:"amount"`
+	Kind           ItemKind `json:"kind,omitempty"`
+	ParticipantIds []int    `json:"participantIds,omitempty"` // who share a product, ignored for taxes and tips
+}
+
+func (item MovementItem) IsProduct() bool {
+	return item.Kind == "" || item.Kind == ProductItem
+}
+
+var ErrUnknownItemKind error = errors.New("The item kind is not supported")
+var ErrInvalidItem error = errors.New("Items must not have negative amounts and every product must be shared by beneficiaries of the movement")
+var ErrItemsAmountMismatch error = errors.New("The movement amount must match the sum of all items' amounts.")
+
+// invariante de que movement.Amount = SUM (movement.Items[i].Amount)
+func EnsureMovementAmountMatchesItemAmounts(movement Movement) error {
+	totalAmount := 0
+	for _, item := range movement.Items {
+		totalAmount += item.Amount
+	}
+	if movement.Amount != totalAmount {
+		return ErrItemsAmountMismatch
+	}
+	return nil
+}
+
+func EnsureItemsAreValid(movement Movement, participantMovements []ParticipantMovement) error {
+	isBeneficiaryByParticipantId := make(map[int]bool)
+	for _, participantMovement := range participantMovements {
+		isBeneficiaryByParticipantId[participantMovement.ParticipantId] = participantMovement.IsBeneficiary()
+	}
+	productsAmount := 0
+	for _, item := range movement.Items {
+		switch item.Kind {
+		case "", ProductItem, TaxItem, TipItem:
+		default:
+			return ErrUnknownItemKind
+		}
+		if item.Amount < 0 {
+			return ErrInvalidItem
+		}
+		if !item.IsProduct() {
+			continue
+		}
+		if len(item.ParticipantIds) == 0 {
+			return ErrInvalidItem
+		}
+		for _, participantId := range item.ParticipantIds {
+			if !isBeneficiaryByParticipantId[participantId] {
+				return ErrInvalidItem
+			}
+		}
+		productsAmount += item.Amount
+	}
+	if productsAmount == 0 && movement.Amount > 0 { // taxes and tips need products to be spread over
+		return ErrInvalidItem
+	}
+	return EnsureMovementAmountMatchesItemAmounts(movement)
+}
+
+// the participants' consumed amounts are derived from the items they shared, so the share is what they paid minus what they consumed
+func BuildParticipantsItemizedShare(movement Movement, participantMovements []ParticipantMovement, policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	consumedByParticipantId, err := BuildConsumedAmountsFromItems(movement, participantMovements, policy)
+	if err != nil {
+		return nil, err
+	}
+	return buildSharesFromConsumedAmounts(movement, participantMovements, consumedByParticipantId), nil
+}
+
+// Each product is split in equal parts among its participants, then taxes and tips are spread over the participants in proportion
+// to the products they consumed. The units that can not be evenly split are allocated following the remainder policy.
+func BuildConsumedAmountsFromItems(movement Movement, participantMovements []ParticipantMovement, policy RemainderPolicy) (map[int]Price, error) {
+	err := EnsureItemsAreValid(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+	sortRemainderRecipients, err := policy.remainderRecipientsSorter(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+
+	consumedByParticipantId := make(map[int]Price)
+	extrasAmount := 0
+	for _, item := range movement.Items {
+		if !item.IsProduct() {
+			extrasAmount += item.Amount
+			continue
+		}
+		if item.Amount == 0 {
+			continue
+		}
+		weightByParticipantId := make(map[int]int)
+		for _, participantId := range item.ParticipantIds {
+			weightByParticipantId[participantId] = 1
+		}
+		partByParticipantId, err := distributeProportionally(item.Amount, weightByParticipantId, sortRemainderRecipients)
+		if err != nil {
+			return nil, err
+		}
+		for participantId, part := range partByParticipantId {
+			consumedByParticipantId[participantId] += part
+		}
+	}
+	if extrasAmount == 0 {
+		return consumedByParticipantId, nil
+	}
+
+	extrasByParticipantId, err := distributeProportionally(extrasAmount, consumedByParticipantId, sortRemainderRecipients)
+	if err != nil {
+		return nil, err
+	}
+	for participantId, extras := range extrasByParticipantId {
+		consumedByParticipantId[participantId] += extras
+	}
+	return consumedByParticipantId, nil
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildParticipantsItemizedShare(t *testing.T) {
+	paidByFirst := []ParticipantMovement{
+		{ParticipantId: 1, Amount: 1300},
+		{ParticipantId: 2, Amount: 0},
+		{ParticipantId: 3, Amount: 0},
+	}
+	tests := []struct {
+		name                 string
+		items                []MovementItem
+		participantMovements []ParticipantMovement
+		expected             shareAmounts
+		expectedError        error
+	}{
+		{
+			name: "Each product shared by its own participants",
+			items: []MovementItem{
+				{Concept: "Vino", Amount: 600, ParticipantIds: []int{1, 2}},
+				{Concept: "Pan", Amount: 300, ParticipantIds: []int{1, 2, 3}},
+				{Concept: "Leche", Amount: 400, ParticipantIds: []int{3}},
+			},
+			participantMovements: paidByFirst,
+			expected:             shareAmounts{1: 900, 2: -400, 3: -500},
+		},
+		{
+			name: "Tax and tip are spread in proportion to the products consumed",
+			items: []MovementItem{
+				{Concept: "Pizza", Amount: 800, ParticipantIds: []int{1, 2}},
+				{Concept: "Postre", Amount: 200, ParticipantIds: []int{3}},
+				{Concept: "IVA", Amount: 200, Kind: TaxItem},
+				{Concept: "Propina", Amount: 100, Kind: TipItem},
+			},
+			participantMovements: paidByFirst,
+			expected:             shareAmounts{1: 780, 2: -520, 3: -260},
+		},
+		{
+			name: "Uneven items follow the remainder policy",
+			items: []MovementItem{
+				{Concept: "Facturas", Amount: 1000, ParticipantIds: []int{1, 2, 3}},
+				{Concept: "IVA", Amount: 300, Kind: TaxItem},
+			},
+			participantMovements: paidByFirst,
+			expected:             shareAmounts{1: 866, 2: -433, 3: -433},
+		},
+		{
+			name: "Items must sum to the movement amount",
+			items: []MovementItem{
+				{Concept: "Vino", Amount: 600, ParticipantIds: []int{1, 2}},
+			},
+			participantMovements: paidByFirst,
+			expectedError:        ErrItemsAmountMismatch,
+		},
+		{
+			name: "Products must be shared by someone",
+			items: []MovementItem{
+				{Concept: "Vino", Amount: 1300},
+			},
+			participantMovements: paidByFirst,
+			expectedError:        ErrInvalidItem,
+		},
+		{
+			name: "Products must be shared by beneficiaries of the movement",
+			items: []MovementItem{
+				{Concept: "Vino", Amount: 1300, ParticipantIds: []int{1, 4}},
+			},
+			participantMovements: paidByFirst,
+			expectedError:        ErrInvalidItem,
+		},
+		{
+			name: "Taxes need products",
+			items: []MovementItem{
+				{Concept: "IVA", Amount: 1300, Kind: TaxItem},
+			},
+			participantMovements: paidByFirst,
+			expectedError:        ErrInvalidItem,
+		},
+		{
+			name: "Unknown item kind",
+			items: []MovementItem{
+				{Concept: "Vino", Amount: 1300, Kind: "discount", ParticipantIds: []int{1}},
+			},
+			participantMovements: paidByFirst,
+			expectedError:        ErrUnknownItemKind,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			movement := Movement{Id: 1, Amount: 1300, SplitStrategy: ItemizedSplit, Items: test.items}
+			shares, err := BuildParticipantsShare(movement, test.participantMovements, DefaultRemainderPolicy)
+			if err != test.expectedError {
+				t.Fatalf("got error %v, expected %v", err, test.expectedError)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(shares, test.expected.toShares()) {
+				t.Errorf("got %v, expected %v", shares, test.expected)
+			}
+			err = EnsureSharesSumToZero(shares)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
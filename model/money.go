@@ -0,0 +1,196 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ISO 4217 currency code (e.g. "ARS", "USD", "EUR")
+type Currency string
+
+const DefaultCurrency Currency = "ARS"
+
+// digits after the decimal separator of the currencies that don't use two (the most common case)
+var minorUnitDigitsByCurrency = map[Currency]int{
+	"CLP": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"PYG": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+var ErrInvalidCurrency error = errors.New("The currency must be an ISO 4217 code (three upper case letters)")
+var ErrInvalidMoney error = errors.New("The money amount is not a valid decimal number for its currency")
+var ErrCurrencyMismatch error = errors.New("Can not operate with money of different currencies")
+
+func EnsureCurrencyIsValid(currency Currency) error {
+	if len(currency) != 3 {
+		return ErrInvalidCurrency
+	}
+	for _, letter := range currency {
+		if letter < 'A' || letter > 'Z' {
+			return ErrInvalidCurrency
+		}
+	}
+	return nil
+}
+
+func (currency Currency) MinorUnitDigits() int {
+	digits, exists := minorUnitDigitsByCurrency[currency]
+	if !exists {
+		return 2
+	}
+	return digits
+}
+
+// Money is an amount expressed in the minor units (e.g. cents) of its currency, so 1234.56 ARS is Money{Amount: 123456, Currency: "ARS"}
+type Money struct {
+	Amount   Price
+	Currency Currency
+}
+
+func NewMoney(amount Price, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parses a decimal amount expressed in major units (e.g. "1234.56" or "-12.5") of the given currency
+func ParseMoney(value string, currency Currency) (Money, error) {
+	err := EnsureCurrencyIsValid(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	value = strings.TrimSpace(value)
+	sign := 1
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+		value = value[1:]
+	}
+	integerPart, fractionalPart, hasSeparator := strings.Cut(value, ".")
+	digits := currency.MinorUnitDigits()
+	if integerPart == "" || (hasSeparator && (fractionalPart == "" || len(fractionalPart) > digits)) {
+		return Money{}, ErrInvalidMoney
+	}
+	fractionalPart += strings.Repeat("0", digits-len(fractionalPart))
+	for _, digit := range integerPart + fractionalPart {
+		if digit < '0' || digit > '9' {
+			return Money{}, ErrInvalidMoney
+		}
+	}
+	amount, err := strconv.Atoi(integerPart + fractionalPart)
+	if err != nil {
+		return Money{}, ErrInvalidMoney
+	}
+	return Money{Amount: sign * amount, Currency: currency}, nil
+}
+
+// Formats the amount in major units, e.g. "1234.56"
+func (money Money) FormatAmount() string {
+	digits := money.Currency.MinorUnitDigits()
+	amount := money.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if digits == 0 {
+		return sign + strconv.Itoa(amount)
+	}
+	unit := 1
+	for i := 0; i < digits; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, digits, amount%unit)
+}
+
+// e.g. "1234.56 ARS"
+func (money Money) String() string {
+	return money.FormatAmount() + " " + string(money.Currency)
+}
+
+func (money Money) IsZero() bool {
+	return money.Amount == 0
+}
+
+func (money Money) Negate() Money {
+	return Money{Amount: -money.Amount, Currency: money.Currency}
+}
+
+func (money Money) Add(other Money) (Money, error) {
+	if money.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: money.Amount + other.Amount, Currency: money.Currency}, nil
+}
+
+func (money Money) Sub(other Money) (Money, error) {
+	return money.Add(other.Negate())
+}
+
+// Sums values that must all be in the given currency
+func SumMoney(currency Currency, values ...Money) (Money, error) {
+	total := NewMoney(0, currency)
+	for _, value := range values {
+		var err error
+		total, err = total.Add(value)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// the currency of every given money, ErrCurrencyMismatch when they are not all in the same one (no money has none)
+func getCommonCurrency(monies ...Money) (Currency, error) {
+	if len(monies) == 0 {
+		return "", nil
+	}
+	currency := monies[0].Currency
+	for _, money := range monies[1:] {
+		if money.Currency != currency {
+			return "", ErrCurrencyMismatch
+		}
+	}
+	return currency, nil
+}
+
+type moneyJson struct {
+	Amount   string   `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// encodes as {"amount":"1234.56","currency":"ARS"} so no precision is lost on the client side
+func (money Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJson{Amount: money.FormatAmount(), Currency: money.Currency})
+}
+
+// decodes {"amount":"1234.56","currency":"ARS"}, the amount may also be given as a json number
+func (money *Money) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency Currency        `json:"currency"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	var amount string
+	err = json.Unmarshal(raw.Amount, &amount)
+	if err != nil {
+		var number json.Number
+		err = json.Unmarshal(raw.Amount, &number)
+		if err != nil {
+			return ErrInvalidMoney
+		}
+		amount = number.String()
+	}
+	parsed, err := ParseMoney(amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*money = parsed
+	return nil
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func ars(amount Price) Money {
+	return NewMoney(amount, "ARS")
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		currency      Currency
+		expected      Money
+		expectedError error
+	}{
+		{name: "Amount with cents", value: "1234.56", currency: "ARS", expected: Money{Amount: 123456, Currency: "ARS"}},
+		{name: "Amount with a single decimal", value: "12.5", currency: "USD", expected: Money{Amount: 1250, Currency: "USD"}},
+		{name: "Amount without decimals", value: "15", currency: "ARS", expected: Money{Amount: 1500, Currency: "ARS"}},
+		{name: "Negative amount", value: "-0.75", currency: "EUR", expected: Money{Amount: -75, Currency: "EUR"}},
+		{name: "Currency without minor units", value: "1500", currency: "CLP", expected: Money{Amount: 1500, Currency: "CLP"}},
+		{name: "Too many decimals", value: "1.234", currency: "ARS", expectedError: ErrInvalidMoney},
+		{name: "Not a number", value: "12,50", currency: "ARS", expectedError: ErrInvalidMoney},
+		{name: "Invalid currency", value: "10", currency: "ars", expectedError: ErrInvalidCurrency},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := ParseMoney(test.value, test.currency)
+			if err != test.expectedError {
+				t.Fatalf("got error %v, expected %v", err, test.expectedError)
+			}
+			if parsed != test.expected {
+				t.Errorf("parsed %v, expected %v", parsed, test.expected)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money    Money
+		expected string
+	}{
+		{money: NewMoney(123456, "ARS"), expected: "1234.56 ARS"},
+		{money: NewMoney(5, "USD"), expected: "0.05 USD"},
+		{money: NewMoney(-1050, "EUR"), expected: "-10.50 EUR"},
+		{money: NewMoney(1500, "JPY"), expected: "1500 JPY"},
+		{money: NewMoney(1234, "KWD"), expected: "1.234 KWD"},
+	}
+
+	for _, test := range tests {
+		if test.money.String() != test.expected {
+			t.Errorf("formatted %q, expected %q", test.money.String(), test.expected)
+		}
+	}
+}
+
+func TestMoneyArithmeticRefusesToMixCurrencies(t *testing.T) {
+	sum, err := NewMoney(1000, "ARS").Add(NewMoney(250, "ARS"))
+	if err != nil || sum != NewMoney(1250, "ARS") {
+		t.Errorf("got %v (error %v), expected 12.50 ARS", sum, err)
+	}
+	difference, err := NewMoney(1000, "ARS").Sub(NewMoney(250, "ARS"))
+	if err != nil || difference != NewMoney(750, "ARS") {
+		t.Errorf("got %v (error %v), expected 7.50 ARS", difference, err)
+	}
+	_, err = NewMoney(1000, "ARS").Add(NewMoney(250, "USD"))
+	if err != ErrCurrencyMismatch {
+		t.Errorf("got error %v, expected %v", err, ErrCurrencyMismatch)
+	}
+	_, err = SumMoney("ARS", NewMoney(1, "ARS"), NewMoney(1, "USD"))
+	if err != ErrCurrencyMismatch {
+		t.Errorf("got error %v, expected %v", err, ErrCurrencyMismatch)
+	}
+}
+
+func TestMoneyJson(t *testing.T) {
+	encoded, err := json.Marshal(NewMoney(123456, "ARS"))
+	if err != nil {
+		t.Fatalf("Failed to encode money: %v", err)
+	}
+	if string(encoded) != `{"amount":"1234.56","currency":"ARS"}` {
+		t.Errorf("encoded %s", encoded)
+	}
+
+	var decoded Money
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil || decoded != NewMoney(123456, "ARS") {
+		t.Errorf("decoded %v (error %v), expected 1234.56 ARS", decoded, err)
+	}
+	err = json.Unmarshal([]byte(`{"amount":12.5,"currency":"USD"}`), &decoded)
+	if err != nil || decoded != NewMoney(1250, "USD") {
+		t.Errorf("decoded %v (error %v), expected 12.50 USD", decoded, err)
+	}
+	err = json.Unmarshal([]byte(`{"amount":"12.5","currency":"dollars"}`), &decoded)
+	if err != ErrInvalidCurrency {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidCurrency)
+	}
+}
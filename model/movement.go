@@ -6,11 +6,22 @@ import (
 )
 
 type Movement struct {
-	Id        int    `json:"id"`
-	GroupId   int    `json:"groupId"`
-	CreatedAt int64  `json:"createdAt"` // unix timestamp, in seconds since epoch
-	Amount    Price  `json:"amount"`
-	Concept   string `json:"concept"`
+	Id            int            `json:"id"`
+	GroupId       int            `json:"groupId"`
+	CreatedAt     int64          `json:"createdAt"` // unix timestamp, in seconds since epoch
+	Amount        Price          `json:"amount"`    // in minor units of the currency
+	Currency      Currency       `json:"currency"`
+	ExchangeRate  float64        `json:"exchangeRate"` // snapshot of how many units of the group's currency one unit of the movement's currency was worth when entered
+	Concept       string         `json:"concept"`
+	SplitStrategy SplitStrategy  `json:"splitStrategy"`
+	Kind          MovementKind   `json:"kind"`
+	Installments  int            `json:"installments,omitempty"` // only meaningful for installments movements, how many monthly installments the amount is paid in
+	StartsAt      int64          `json:"startsAt,omitempty"`     // only meaningful for installments movements, unix timestamp when the first installment becomes due
+	Items         []MovementItem `json:"items,omitempty"`        // only meaningful for itemised splits
+	Surcharges    []Surcharge    `json:"surcharges,omitempty"`   // extras (tip, fees) included in the amount and allocated in proportion to the consumed amounts
+	PeriodFrom    int64          `json:"periodFrom,omitempty"`   // only meaningful for presence splits, unix timestamp of the day the movement took place or the first day of the period it spans
+	PeriodTo      int64          `json:"periodTo,omitempty"`     // only meaningful for presence splits, unix timestamp of the last day of the period (e.g. the check out of a rental)
+	ShareRules    []ShareRule    `json:"shareRules,omitempty"`   // caps and sponsors applied over the split
 }
 
 func (movement Movement) GetId() int {
@@ -21,6 +32,21 @@ func (movement *Movement) SetId(id int) {
 	movement.Id = id
 }
 
+func (movement Movement) GetAmount() Money {
+	return NewMoney(movement.Amount, movement.Currency)
+}
+
+// an expense is split among its beneficiaries while a transfer moves money from one participant to another, a movement without kind is an expense
+type MovementKind string
+
+const (
+	ExpenseKind      MovementKind = "expense"
+	TransferKind     MovementKind = "transfer"
+	InstallmentsKind MovementKind = "installments" // an expense paid in monthly installments, split as an expense but only the due installments are owed
+)
+
+var ErrUnknownMovementKind error = errors.New("The movement kind is not supported")
+
 type TransferMovement struct {
 	Movement
 	FromParticipantId int `json:"fromParticipantId"`
@@ -28,10 +54,13 @@ type TransferMovement struct {
 }
 
 type ParticipantMovement struct {
-	Id            int   `json:"id"`
-	MovementId    int   `json:"movementId"`
-	ParticipantId int   `json:"participantId"`
-	Amount        Price `json:"amount"`
+	Id            int             `json:"id"`
+	MovementId    int             `json:"movementId"`
+	ParticipantId int             `json:"participantId"`
+	Amount        Price           `json:"amount"`
+	Weight        int             `json:"weight"`   // only meaningful for weighted (a weight) and percentage (a percentage) splits
+	Consumed      Price           `json:"consumed"` // only meaningful for exact splits, how much of the movement's amount the participant consumed
+	Role          ParticipantRole `json:"role"`
 }
 
 func (participantMovement ParticipantMovement) GetId() int {
@@ -42,37 +71,195 @@ func (participantMovement *ParticipantMovement) SetId(id int) {
 	participantMovement.Id = id
 }
 
-type ParticipantShareByParticipantId map[int]Price
+// a payer puts money for the movement while a beneficiary shares its cost, a participant movement without role is both.
+// A sponsor does not share the cost but takes over the share of the participants it sponsors (see ShareRule).
+type ParticipantRole string
 
-type BalanceSheet interface {
-	GetCredit(participantId int) (int, error)
-	GetDebt(participantId int) (int, error)
+const (
+	PayerRole               ParticipantRole = "payer"
+	BeneficiaryRole         ParticipantRole = "beneficiary"
+	PayerAndBeneficiaryRole ParticipantRole = "both"
+	SponsorRole             ParticipantRole = "sponsor"
+)
+
+func (participantMovement ParticipantMovement) IsPayer() bool {
+	return participantMovement.Role != BeneficiaryRole
 }
 
-type DebitCreditMap map[int]map[int]Price
+func (participantMovement ParticipantMovement) IsBeneficiary() bool {
+	return participantMovement.Role != PayerRole && participantMovement.Role != SponsorRole
+}
 
-func BuildParticipantsEqualShare(movement Movement, participantMovements []ParticipantMovement) ParticipantShareByParticipantId {
-	equalShare := movement.Amount / len(participantMovements)
-	participantShareByParticipantId := make(map[int]Price)
+// whether the participant may end up owing money for the movement
+func (participantMovement ParticipantMovement) CanOwe() bool {
+	return participantMovement.IsBeneficiary() || participantMovement.Role == SponsorRole
+}
+
+var ErrUnknownParticipantRole error = errors.New("The participant role is not supported")
+var ErrBeneficiaryOnlyCanNotPay error = errors.New("A participant that is only a beneficiary can not pay for the movement")
+var ErrPayerOnlyCanNotConsume error = errors.New("A participant that is only a payer can not share the movement's cost")
+var ErrMovementWithoutBeneficiaries error = errors.New("The movement must have at least one beneficiary")
+
+func EnsureParticipantRolesAreConsistent(participantMovements []ParticipantMovement) error {
+	hasBeneficiaries := false
 	for _, participantMovement := range participantMovements {
-		participantShare := participantMovement.Amount - equalShare
-		participantShareByParticipantId[participantMovement.ParticipantId] = participantShare
+		switch participantMovement.Role {
+		case "", PayerRole, BeneficiaryRole, PayerAndBeneficiaryRole, SponsorRole:
+		default:
+			return ErrUnknownParticipantRole
+		}
+		if !participantMovement.IsPayer() && participantMovement.Amount != 0 {
+			return ErrBeneficiaryOnlyCanNotPay
+		}
+		if !participantMovement.IsBeneficiary() && (participantMovement.Weight != 0 || participantMovement.Consumed != 0) {
+			return ErrPayerOnlyCanNotConsume
+		}
+		hasBeneficiaries = hasBeneficiaries || participantMovement.IsBeneficiary()
+	}
+	if len(participantMovements) > 0 && !hasBeneficiaries {
+		return ErrMovementWithoutBeneficiaries
+	}
+	return nil
+}
+
+// The share of each participant, every share is in the same currency (the one of the movements it was built from) and the
+// functions operating with shares refuse to mix currencies
+type ParticipantShareByParticipantId map[int]Money
+
+// the currency of the shares, ErrCurrencyMismatch when they are not all in the same one (shares without participants have none)
+func (shares ParticipantShareByParticipantId) getCurrency() (Currency, error) {
+	return getCommonCurrency(shares.getMonies()...)
+}
+
+func (shares ParticipantShareByParticipantId) getMonies() []Money {
+	monies := make([]Money, 0, len(shares))
+	for _, share := range shares {
+		monies = append(monies, share)
+	}
+	return monies
+}
+
+// the amounts of the shares, which must have been checked to be in the same currency
+func (shares ParticipantShareByParticipantId) getAmounts() map[int]Price {
+	amountByParticipantId := make(map[int]Price, len(shares))
+	for participantId, share := range shares {
+		amountByParticipantId[participantId] = share.Amount
 	}
+	return amountByParticipantId
+}
+
+func newParticipantShares(amountByParticipantId map[int]Price, currency Currency) ParticipantShareByParticipantId {
+	shares := make(ParticipantShareByParticipantId, len(amountByParticipantId))
+	for participantId, amount := range amountByParticipantId {
+		shares[participantId] = NewMoney(amount, currency)
+	}
+	return shares
+}
+
+type BalanceSheet interface {
+	GetCredit(participantId int) (Money, error)
+	GetDebt(participantId int) (Money, error)
+}
+
+// What each debtor owes to each creditor, every amount is in the same currency (the one of the shares it was built from)
+type DebitCreditMap map[int]map[int]Money
+
+// the currency of the amounts, ErrCurrencyMismatch when they are not all in the same one (a map without debts has none)
+func (debitCreditMap DebitCreditMap) getCurrency() (Currency, error) {
+	return getCommonCurrency(debitCreditMap.getMonies()...)
+}
+
+func (debitCreditMap DebitCreditMap) getMonies() []Money {
+	monies := []Money{}
+	for _, amountByCreditorId := range debitCreditMap {
+		for _, amount := range amountByCreditorId {
+			monies = append(monies, amount)
+		}
+	}
+	return monies
+}
+
+// the amounts of the debts, which must have been checked to be in the same currency
+func (debitCreditMap DebitCreditMap) getAmounts() map[int]map[int]Price {
+	amounts := make(map[int]map[int]Price, len(debitCreditMap))
+	for debtorId, amountByCreditorId := range debitCreditMap {
+		amounts[debtorId] = make(map[int]Price, len(amountByCreditorId))
+		for creditorId, amount := range amountByCreditorId {
+			amounts[debtorId][creditorId] = amount.Amount
+		}
+	}
+	return amounts
+}
+
+func newDebitCreditMap(amounts map[int]map[int]Price, currency Currency) DebitCreditMap {
+	debitCreditMap := make(DebitCreditMap, len(amounts))
+	for debtorId, amountByCreditorId := range amounts {
+		debitCreditMap[debtorId] = make(map[int]Money, len(amountByCreditorId))
+		for creditorId, amount := range amountByCreditorId {
+			debitCreditMap[debtorId][creditorId] = NewMoney(amount, currency)
+		}
+	}
+	return debitCreditMap
+}
+
+// the units that can not be evenly split are allocated following the default remainder policy
+func BuildParticipantsEqualShare(movement Movement, participantMovements []ParticipantMovement) ParticipantShareByParticipantId {
+	participantShareByParticipantId, _ := buildParticipantsEqualShare(movement, participantMovements, DefaultRemainderPolicy) // only fails when there are no beneficiaries (see EnsureParticipantRolesAreConsistent) or negative weights
 	return participantShareByParticipantId
 }
 
 func BuildParticipantsTransferShare(movement TransferMovement) ParticipantShareByParticipantId {
-	participantShareByParticipantId := make(map[int]Price)
-	participantShareByParticipantId[movement.FromParticipantId] = movement.Amount // el que da queda acreditando
-	participantShareByParticipantId[movement.ToParticipantId] = -movement.Amount  // el que recibe queda adeudando
+	participantShareByParticipantId := make(ParticipantShareByParticipantId)
+	participantShareByParticipantId[movement.FromParticipantId] = movement.GetAmount()        // el que da queda acreditando
+	participantShareByParticipantId[movement.ToParticipantId] = movement.GetAmount().Negate() // el que recibe queda adeudando
 	return participantShareByParticipantId
 }
 
 func BuildParticipantsTransferMovements(movement TransferMovement) []ParticipantMovement {
 	return []ParticipantMovement{
-		{ParticipantId: movement.FromParticipantId, MovementId: movement.Id, Amount: movement.Amount}, // el que da pone todo el monto (amount)
-		{ParticipantId: movement.ToParticipantId, MovementId: movement.Id, Amount: 0},                 // el que recibe no pone (0)
+		{ParticipantId: movement.FromParticipantId, MovementId: movement.Id, Amount: movement.Amount, Role: PayerRole}, // el que da pone todo el monto (amount)
+		{ParticipantId: movement.ToParticipantId, MovementId: movement.Id, Amount: 0, Role: BeneficiaryRole},           // el que recibe no pone (0)
+	}
+}
+
+var ErrInvalidTransfer error = errors.New("A transfer must move a positive amount between two different participants")
+
+func EnsureTransferIsValid(movement TransferMovement) error {
+	if movement.Amount <= 0 || movement.FromParticipantId == movement.ToParticipantId {
+		return ErrInvalidTransfer
+	}
+	return nil
+}
+
+// rebuilds a transfer from a persisted movement and its participant movements (the inverse of BuildParticipantsTransferMovements)
+func BuildTransferMovement(movement Movement, participantMovements []ParticipantMovement) (TransferMovement, error) {
+	transfer := TransferMovement{Movement: movement}
+	if len(participantMovements) != 2 {
+		return transfer, ErrInvalidTransfer
+	}
+	for _, participantMovement := range participantMovements {
+		switch participantMovement.Role {
+		case PayerRole:
+			transfer.FromParticipantId = participantMovement.ParticipantId
+		case BeneficiaryRole:
+			transfer.ToParticipantId = participantMovement.ParticipantId
+		default:
+			return transfer, ErrInvalidTransfer
+		}
+	}
+	if transfer.FromParticipantId == 0 || transfer.ToParticipantId == 0 {
+		return transfer, ErrInvalidTransfer
 	}
+	return transfer, EnsureTransferIsValid(transfer)
+}
+
+var ErrNegativeMovementAmount error = errors.New("The movement amount must not be negative")
+
+func EnsureMovementAmountIsNotNegative(movement Movement) error {
+	if movement.Amount < 0 {
+		return ErrNegativeMovementAmount
+	}
+	return nil
 }
 
 var ErrMovementAmountMismatch error = errors.New("The movement amount must match the sum of all participants' amounts.")
@@ -90,34 +277,48 @@ func EnsureMovementAmountMatchesParticipantAmounts(movement Movement, participan
 	}
 }
 
+var ErrMovementConsumedAmountMismatch error = errors.New("The movement amount must match the sum of all participants' consumed amounts.")
+
+// invariante de que movement.Amount = SUM (participantMovements[i].consumed)
+func EnsureMovementAmountMatchesParticipantConsumedAmounts(movement Movement, participantMovements []ParticipantMovement) error {
+	totalConsumed := 0
+	for _, participantMovement := range participantMovements {
+		totalConsumed += participantMovement.Consumed
+	}
+	if movement.Amount != totalConsumed {
+		return ErrMovementConsumedAmountMismatch
+	} else {
+		return nil
+	}
+}
+
 var ErrSharesDoNotSumToZero error = errors.New("The sum of shares must equal zero")
 
 // invariante de que 0 = SUM (participantShareByParticipantId[i].amount)
 func EnsureSharesSumToZero(participantShareByParticipantId ParticipantShareByParticipantId) error {
-	totalAmount := 0
+	currency, err := participantShareByParticipantId.getCurrency()
+	if err != nil {
+		return err
+	}
+	totalAmount := NewMoney(0, currency)
 	for _, share := range participantShareByParticipantId {
-		totalAmount += share
+		totalAmount, _ = totalAmount.Add(share) // already checked to be in the same currency
 	}
-	if 0 != totalAmount {
+	if !totalAmount.IsZero() {
 		return ErrSharesDoNotSumToZero
 	} else {
 		return nil
 	}
 }
 
-func deepCopyParticipantShareByParticipantId(original map[int]Price) map[int]Price {
-	_copy := make(map[int]Price)
-	for key, value := range original {
-		_copy[key] = value
-	}
-	return _copy
-}
-
 // generacion de deudas y créditos para cada participante en relación a los demás participantes
-func BuildDebitCreditMap(participantMovements []ParticipantMovement, shares ParticipantShareByParticipantId) DebitCreditMap {
-	debitCreditMap := make(DebitCreditMap)
-	sharesCopy := deepCopyParticipantShareByParticipantId(shares)
-	shares = sharesCopy // using a copy in order to leave untouch the "shares" argument
+func BuildDebitCreditMap(participantMovements []ParticipantMovement, participantShares ParticipantShareByParticipantId) (DebitCreditMap, error) {
+	currency, err := participantShares.getCurrency()
+	if err != nil {
+		return nil, err
+	}
+	debitCreditMap := make(map[int]map[int]Price)
+	shares := participantShares.getAmounts() // a copy in order to leave untouch the "participantShares" argument
 	participantIds := getSortedParticipantIds(shares)
 	for _, participantMovement := range participantMovements {
 		participantShare := shares[participantMovement.ParticipantId]
@@ -151,10 +352,77 @@ func BuildDebitCreditMap(participantMovements []ParticipantMovement, shares Part
 			}
 		}
 	}
-	return debitCreditMap
+	return newDebitCreditMap(debitCreditMap, currency), nil
 }
 
-func getSortedParticipantIds(m ParticipantShareByParticipantId) []int {
+// Generación de deudas y créditos en la que cada deudor le debe a cada acreedor en proporción al crédito de este último,
+// depende sólo de las partes así que el resultado es el mismo sin importar el orden de participantMovements.
+// Las unidades que se pierden en la división entera se reparten de forma que cada deudor siga debiendo su deuda y a cada acreedor se le siga debiendo su crédito.
+func BuildProportionalDebitCreditMap(participantShares ParticipantShareByParticipantId) (DebitCreditMap, error) {
+	currency, err := participantShares.getCurrency()
+	if err != nil {
+		return nil, err
+	}
+	shares := participantShares.getAmounts()
+	var debtorIds, creditorIds []int
+	totalCredit := 0
+	for _, id := range getSortedParticipantIds(shares) {
+		if shares[id] < 0 {
+			debtorIds = append(debtorIds, id)
+		} else if shares[id] > 0 {
+			creditorIds = append(creditorIds, id)
+			totalCredit += shares[id]
+		}
+	}
+
+	debitCreditMap := make(map[int]map[int]Price)
+	if totalCredit == 0 {
+		return make(DebitCreditMap), nil
+	}
+	remainderByCreditorIdByDebtorId := make(map[int]map[int]int)
+	missingByCreditorId := make(map[int]Price) // lo que falta asignarle a cada acreedor por las divisiones enteras
+	for _, creditorId := range creditorIds {
+		missingByCreditorId[creditorId] = shares[creditorId]
+	}
+	for _, debtorId := range debtorIds {
+		debitCreditMap[debtorId] = make(map[int]Price)
+		remainderByCreditorIdByDebtorId[debtorId] = make(map[int]int)
+		for _, creditorId := range creditorIds {
+			exactAmount := -shares[debtorId] * shares[creditorId]
+			debitCreditMap[debtorId][creditorId] = exactAmount / totalCredit
+			remainderByCreditorIdByDebtorId[debtorId][creditorId] = exactAmount % totalCredit
+			missingByCreditorId[creditorId] -= debitCreditMap[debtorId][creditorId]
+		}
+	}
+
+	// cada deudor entrega las unidades que le faltan a los acreedores a los que más les falta recibir (luego al de mayor resto y luego al de menor id)
+	for _, debtorId := range debtorIds {
+		missing := -shares[debtorId]
+		for _, amount := range debitCreditMap[debtorId] {
+			missing -= amount
+		}
+		sortedCreditorIds := append([]int{}, creditorIds...)
+		sort.SliceStable(sortedCreditorIds, func(i, j int) bool {
+			left, right := sortedCreditorIds[i], sortedCreditorIds[j]
+			if missingByCreditorId[left] != missingByCreditorId[right] {
+				return missingByCreditorId[left] > missingByCreditorId[right]
+			}
+			return remainderByCreditorIdByDebtorId[debtorId][left] > remainderByCreditorIdByDebtorId[debtorId][right]
+		})
+		for i := 0; i < missing && i < len(sortedCreditorIds); i++ {
+			debitCreditMap[debtorId][sortedCreditorIds[i]]++
+			missingByCreditorId[sortedCreditorIds[i]]--
+		}
+		for creditorId, amount := range debitCreditMap[debtorId] {
+			if amount == 0 {
+				delete(debitCreditMap[debtorId], creditorId)
+			}
+		}
+	}
+	return newDebitCreditMap(debitCreditMap, currency), nil
+}
+
+func getSortedParticipantIds[V any](m map[int]V) []int {
 	keys := make([]int, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
@@ -163,7 +431,7 @@ func getSortedParticipantIds(m ParticipantShareByParticipantId) []int {
 	return keys
 }
 
-func addDebitCreditMap(source DebitCreditMap, target DebitCreditMap) {
+func addDebitCreditMap(source map[int]map[int]Price, target map[int]map[int]Price) {
 	for i, innerMap := range source {
 		_, exists := target[i]
 		if !exists {
@@ -179,16 +447,43 @@ func addDebitCreditMap(source DebitCreditMap, target DebitCreditMap) {
 	}
 }
 
-func SumDebitCreditMaps(left DebitCreditMap, right DebitCreditMap) DebitCreditMap {
-	result := make(DebitCreditMap)
+func SumDebitCreditMaps(left DebitCreditMap, right DebitCreditMap) (DebitCreditMap, error) {
+	currency, err := getCommonCurrency(append(left.getMonies(), right.getMonies()...)...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]map[int]Price)
 
-	addDebitCreditMap(left, result)
-	addDebitCreditMap(right, result)
+	addDebitCreditMap(left.getAmounts(), result)
+	addDebitCreditMap(right.getAmounts(), result)
 
-	return result
+	return newDebitCreditMap(result, currency), nil
 }
 
-func addParticipantShare(source ParticipantShareByParticipantId, target ParticipantShareByParticipantId) {
+// Nets the opposite debts of each pair of participants (A owes B 10 and B owes A 4 becomes A owes B 6), dropping the ones that cancel out
+func NetDebitCreditMap(debitCreditMap DebitCreditMap) (DebitCreditMap, error) {
+	currency, err := debitCreditMap.getCurrency()
+	if err != nil {
+		return nil, err
+	}
+	amounts := debitCreditMap.getAmounts()
+	result := make(map[int]map[int]Price)
+	for debtorId, innerMap := range amounts {
+		for creditorId, amount := range innerMap {
+			netAmount := amount - amounts[creditorId][debtorId]
+			if netAmount > 0 {
+				_, exists := result[debtorId]
+				if !exists {
+					result[debtorId] = make(map[int]Price)
+				}
+				result[debtorId][creditorId] = netAmount
+			}
+		}
+	}
+	return newDebitCreditMap(result, currency), nil
+}
+
+func addParticipantShare(source map[int]Price, target map[int]Price) {
 	for id, value := range source {
 		_, exists := target[id]
 		if !exists {
@@ -198,11 +493,15 @@ func addParticipantShare(source ParticipantShareByParticipantId, target Particip
 	}
 }
 
-func SumParticipantShares(left ParticipantShareByParticipantId, right ParticipantShareByParticipantId) ParticipantShareByParticipantId {
-	result := make(ParticipantShareByParticipantId)
+func SumParticipantShares(left ParticipantShareByParticipantId, right ParticipantShareByParticipantId) (ParticipantShareByParticipantId, error) {
+	currency, err := getCommonCurrency(append(left.getMonies(), right.getMonies()...)...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]Price)
 
-	addParticipantShare(left, result)
-	addParticipantShare(right, result)
+	addParticipantShare(left.getAmounts(), result)
+	addParticipantShare(right.getAmounts(), result)
 
-	return result
+	return newParticipantShares(result, currency), nil
 }
@@ -4,14 +4,30 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/vituchon/splitify/util"
 )
 
+// the shares of the tests as bare amounts, in the currency of their movements (which have none)
+type shareAmounts map[int]Price
+
+func (amounts shareAmounts) toShares() ParticipantShareByParticipantId {
+	return newParticipantShares(amounts, "")
+}
+
+// the debts of the tests as bare amounts, in the currency of their movements (which have none)
+type debtAmounts map[int]map[int]Price
+
+func (amounts debtAmounts) toDebitCreditMap() DebitCreditMap {
+	return newDebitCreditMap(amounts, "")
+}
+
 func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 	tests := []struct {
 		name                 string
 		movement             Movement
 		participantMovements []ParticipantMovement
-		expected             DebitCreditMap
+		expected             debtAmounts
 	}{
 		{
 			name: "Movement fully covered by participant 1, resulting in participant 2 owing",
@@ -25,7 +41,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 1000},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 0},
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				2: {1: 500},
 			},
 		},
@@ -41,7 +57,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 0},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 1000},
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				1: {2: 500},
 			},
 		},
@@ -57,7 +73,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 500},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 500},
 			},
-			expected: DebitCreditMap{},
+			expected: debtAmounts{},
 		},
 		{
 			name: "Movement partially split, participant 2 owes participant 1",
@@ -71,7 +87,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 800},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 200},
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				2: {1: 300},
 			},
 		},
@@ -88,7 +104,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 0},
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				2: {1: 300},
 				3: {1: 300},
 			},
@@ -106,7 +122,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 200},
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				2: {1: 100},
 				3: {1: 300},
 			},
@@ -125,7 +141,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
 				{Id: 4, ParticipantId: 4, MovementId: 1, Amount: 200},
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				3: {1: 150, 2: 100},
 				4: {2: 50},
 			},
@@ -144,7 +160,7 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 200},
 				{Id: 4, ParticipantId: 4, MovementId: 1, Amount: 0},
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				3: {1: 50},
 				4: {1: 100, 2: 150},
 			},
@@ -162,7 +178,10 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 			if err != nil {
 				t.Fatal(err.Error())
 			}
-			generated := BuildDebitCreditMap(test.participantMovements, participantShareByParticipantId)
+			generated, err := BuildDebitCreditMap(test.participantMovements, participantShareByParticipantId)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
 			if !areEquals(generated, test.expected) {
 				t.Errorf("generated %v, expected %v", generated, test.expected)
 			}
@@ -170,12 +189,130 @@ func TestCalculateDebitCreditMapForEqualShare(t *testing.T) {
 	}
 }
 
+func TestCalculateProportionalDebitCreditMapIsOrderIndependent(t *testing.T) {
+	tests := []struct {
+		name                 string
+		movement             Movement
+		participantMovements []ParticipantMovement
+		expected             debtAmounts
+	}{
+		{
+			name:     "Movement partially covered by participant 1 and 2, participant 3 and 4 owes in not equals shares",
+			movement: Movement{Id: 1, Amount: 1000},
+			participantMovements: []ParticipantMovement{
+				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 400},
+				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 400},
+				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
+				{Id: 4, ParticipantId: 4, MovementId: 1, Amount: 200},
+			},
+			expected: debtAmounts{
+				3: {1: 125, 2: 125},
+				4: {1: 25, 2: 25},
+			},
+		},
+		{
+			name:     "Debts that can not be evenly allocated",
+			movement: Movement{Id: 1, Amount: 800},
+			participantMovements: []ParticipantMovement{
+				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 517},
+				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 283},
+				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
+				{Id: 4, ParticipantId: 4, MovementId: 1, Amount: 0},
+			},
+			expected: debtAmounts{
+				3: {1: 159, 2: 41},
+				4: {1: 158, 2: 42},
+			},
+		},
+		{
+			name:     "Single unit debts",
+			movement: Movement{Id: 1, Amount: 4},
+			participantMovements: []ParticipantMovement{
+				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 2},
+				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 2},
+				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
+				{Id: 4, ParticipantId: 4, MovementId: 1, Amount: 0},
+			},
+			expected: debtAmounts{
+				3: {1: 1},
+				4: {2: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, participantMovements := range util.GeneratePermutations(test.participantMovements) {
+				shares := BuildParticipantsEqualShare(test.movement, participantMovements)
+				err := EnsureSharesSumToZero(shares)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				generated, err := BuildProportionalDebitCreditMap(shares)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				if !areEquals(generated, test.expected) {
+					t.Fatalf("generated %v, expected %v for order %v", generated, test.expected, participantMovements)
+				}
+
+				totalByParticipantId := make(map[int]Price)
+				for debtorId, innerMap := range generated {
+					for creditorId, amount := range innerMap {
+						totalByParticipantId[debtorId] -= amount.Amount
+						totalByParticipantId[creditorId] += amount.Amount
+					}
+				}
+				for id, share := range shares.getAmounts() {
+					if totalByParticipantId[id] != share {
+						t.Fatalf("participant %d has share %d but its debts and credits add up to %d", id, share, totalByParticipantId[id])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateDebitCreditMapForPayersAndBeneficiaries(t *testing.T) {
+	// participant 1 pays a gift for participant 2 that only participants 3 and 4 share, while participant 5 pays and shares part of it
+	movement := Movement{Id: 1, Amount: 3000}
+	participantMovements := []ParticipantMovement{
+		{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 2000, Role: PayerRole},
+		{Id: 2, ParticipantId: 3, MovementId: 1, Amount: 0, Role: BeneficiaryRole},
+		{Id: 3, ParticipantId: 4, MovementId: 1, Amount: 0, Role: BeneficiaryRole},
+		{Id: 4, ParticipantId: 5, MovementId: 1, Amount: 1000, Role: PayerAndBeneficiaryRole},
+	}
+	err := EnsureMovementAmountMatchesParticipantAmounts(movement, participantMovements)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	shares, err := BuildParticipantsShare(movement, participantMovements, DefaultRemainderPolicy)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedShares := shareAmounts{1: 2000, 3: -1000, 4: -1000, 5: 0}
+	if !reflect.DeepEqual(shares, expectedShares.toShares()) {
+		t.Fatalf("generated share %v, expected share %v", shares, expectedShares)
+	}
+	generated, err := BuildDebitCreditMap(participantMovements, shares)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := debtAmounts{
+		3: {1: 1000},
+		4: {1: 1000},
+	}
+	if !areEquals(generated, expected) {
+		t.Errorf("generated %v, expected %v", generated, expected)
+	}
+}
+
 func TestCalculateDebitCreditMapForTransfer(t *testing.T) {
 	tests := []struct {
 		name             string
 		transferMovement TransferMovement
-		shares           ParticipantShareByParticipantId
-		expected         DebitCreditMap
+		shares           shareAmounts
+		expected         debtAmounts
 	}{
 		{
 			name: "Participant 1 transfer to participant 2, participant 2 (reciever) owes participant 1 (emiter)",
@@ -189,11 +326,11 @@ func TestCalculateDebitCreditMapForTransfer(t *testing.T) {
 				FromParticipantId: 1,
 				ToParticipantId:   2,
 			},
-			shares: ParticipantShareByParticipantId{
+			shares: shareAmounts{
 				1: 1000,
 				2: -1000,
 			},
-			expected: DebitCreditMap{
+			expected: debtAmounts{
 				2: {1: 1000},
 			},
 		},
@@ -211,10 +348,13 @@ func TestCalculateDebitCreditMapForTransfer(t *testing.T) {
 			if err != nil {
 				t.Fatal(err.Error())
 			}
-			if !reflect.DeepEqual(participantShareByParticipantId, test.shares) {
+			if !reflect.DeepEqual(participantShareByParticipantId, test.shares.toShares()) {
 				t.Fatalf("generated share %v, expected share %v", participantShareByParticipantId, test.shares)
 			}
-			generated := BuildDebitCreditMap(participantMovements, participantShareByParticipantId)
+			generated, err := BuildDebitCreditMap(participantMovements, participantShareByParticipantId)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
 			if !areEquals(generated, test.expected) {
 				t.Errorf("generated balance %v, expected balance %v", generated, test.expected)
 			}
@@ -222,13 +362,35 @@ func TestCalculateDebitCreditMapForTransfer(t *testing.T) {
 	}
 }
 
+func TestBuildTransferMovementFromParticipantMovements(t *testing.T) {
+	transferMovement := TransferMovement{
+		Movement:          Movement{Id: 1, Amount: 1000, Kind: TransferKind},
+		FromParticipantId: 1,
+		ToParticipantId:   2,
+	}
+	participantMovements := BuildParticipantsTransferMovements(transferMovement)
+	rebuilt, err := BuildTransferMovement(transferMovement.Movement, participantMovements)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(rebuilt, transferMovement) {
+		t.Fatalf("rebuilt transfer %v, expected transfer %v", rebuilt, transferMovement)
+	}
+
+	participantMovements[1].Role = PayerRole
+	_, err = BuildTransferMovement(transferMovement.Movement, participantMovements)
+	if err != ErrInvalidTransfer {
+		t.Fatalf("generated error %v, expected error %v", err, ErrInvalidTransfer)
+	}
+}
+
 func TestCalculateSumDebitCreditMaps(t *testing.T) {
 	tests := []struct {
 		name                  string
 		movement              Movement
 		participantMovements  []ParticipantMovement
-		expectedStepMap       DebitCreditMap
-		expectedAcumulatedMap DebitCreditMap
+		expectedStepMap       debtAmounts
+		expectedAcumulatedMap debtAmounts
 	}{
 		{
 			name: "Movement fully covered by participant 1, resulting in participant 2 owing",
@@ -242,10 +404,10 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 1000},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 0},
 			},
-			expectedStepMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{
 				2: {1: 500},
 			},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedAcumulatedMap: debtAmounts{
 				2: {1: 500},
 			},
 		},
@@ -261,10 +423,10 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 0},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 1000},
 			},
-			expectedStepMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{
 				1: {2: 500},
 			},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedAcumulatedMap: debtAmounts{
 				1: {2: 500},
 				2: {1: 500},
 			},
@@ -281,8 +443,8 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 500},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 500},
 			},
-			expectedStepMap: DebitCreditMap{},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{},
+			expectedAcumulatedMap: debtAmounts{
 				1: {2: 500},
 				2: {1: 500},
 			},
@@ -299,10 +461,10 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 1, ParticipantId: 1, MovementId: 1, Amount: 800},
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 200},
 			},
-			expectedStepMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{
 				2: {1: 300},
 			},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedAcumulatedMap: debtAmounts{
 				1: {2: 500},
 				2: {1: 800},
 			},
@@ -320,11 +482,11 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 0},
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
 			},
-			expectedStepMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{
 				2: {1: 300},
 				3: {1: 300},
 			},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedAcumulatedMap: debtAmounts{
 				1: {2: 500},
 				2: {1: 1100},
 				3: {1: 300},
@@ -343,11 +505,11 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 2, ParticipantId: 2, MovementId: 1, Amount: 200},
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
 			},
-			expectedStepMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{
 				2: {1: 100},
 				3: {1: 300},
 			},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedAcumulatedMap: debtAmounts{
 				1: {2: 500},
 				2: {1: 1200},
 				3: {1: 600},
@@ -367,11 +529,11 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 0},
 				{Id: 4, ParticipantId: 4, MovementId: 1, Amount: 200},
 			},
-			expectedStepMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{
 				3: {1: 150, 2: 100},
 				4: {2: 50},
 			},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedAcumulatedMap: debtAmounts{
 				1: {2: 500},
 				2: {1: 1200},
 				3: {1: 750, 2: 100},
@@ -392,11 +554,11 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 				{Id: 3, ParticipantId: 3, MovementId: 1, Amount: 200},
 				{Id: 4, ParticipantId: 4, MovementId: 1, Amount: 0},
 			},
-			expectedStepMap: DebitCreditMap{
+			expectedStepMap: debtAmounts{
 				3: {1: 50},
 				4: {1: 100, 2: 150},
 			},
-			expectedAcumulatedMap: DebitCreditMap{
+			expectedAcumulatedMap: debtAmounts{
 				1: {2: 500},
 				2: {1: 1200},
 				3: {1: 800, 2: 100},
@@ -416,12 +578,18 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 		if err != nil {
 			t.Fatal(err.Error())
 		}
-		generated := BuildDebitCreditMap(test.participantMovements, participantShareByParticipantId)
+		generated, err := BuildDebitCreditMap(test.participantMovements, participantShareByParticipantId)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
 		if !areEquals(generated, test.expectedStepMap) {
 			t.Errorf("generated %v, expected %v", generated, test.expectedStepMap)
 		}
 
-		acumulatedMap = SumDebitCreditMaps(generated, acumulatedMap)
+		acumulatedMap, err = SumDebitCreditMaps(generated, acumulatedMap)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
 		if !areEquals(acumulatedMap, test.expectedAcumulatedMap) {
 			t.Errorf("%s acumulated generated %v, acumulated expected %v", test.name, acumulatedMap, test.expectedAcumulatedMap)
 		}
@@ -447,23 +615,29 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 	if err != nil {
 		t.Fatal(err.Error())
 	}
-	expectedShare := ParticipantShareByParticipantId{
+	expectedShare := shareAmounts{
 		1: -1000,
 		2: 1000,
 	}
-	if !reflect.DeepEqual(participantShareByParticipantId, expectedShare) {
+	if !reflect.DeepEqual(participantShareByParticipantId, expectedShare.toShares()) {
 		t.Fatalf("generated share %v, expected share %v", participantShareByParticipantId, expectedShare)
 	}
 
-	generated := BuildDebitCreditMap(participantMovements, participantShareByParticipantId)
-	expectedBalance := DebitCreditMap{
+	generated, err := BuildDebitCreditMap(participantMovements, participantShareByParticipantId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedBalance := debtAmounts{
 		1: {2: 1000},
 	}
 	if !areEquals(generated, expectedBalance) {
 		t.Fatalf("generated %v, expected %v", generated, expectedBalance)
 	}
-	acumulatedMap = SumDebitCreditMaps(acumulatedMap, generated)
-	expectedAcumulatedBalance := DebitCreditMap{
+	acumulatedMap, err = SumDebitCreditMaps(acumulatedMap, generated)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedAcumulatedBalance := debtAmounts{
 		1: {2: 1500},
 		2: {1: 1200},
 		3: {1: 800, 2: 100},
@@ -474,7 +648,68 @@ func TestCalculateSumDebitCreditMaps(t *testing.T) {
 	}
 }
 
-func areEquals(left, right DebitCreditMap) bool {
+func TestNetDebitCreditMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    debtAmounts
+		expected debtAmounts
+	}{
+		{
+			name:     "Empty map",
+			input:    debtAmounts{},
+			expected: debtAmounts{},
+		},
+		{
+			name:     "Single debt is left untouched",
+			input:    debtAmounts{1: {2: 10}},
+			expected: debtAmounts{1: {2: 10}},
+		},
+		{
+			name:     "Opposite debts are netted",
+			input:    debtAmounts{1: {2: 10}, 2: {1: 4}},
+			expected: debtAmounts{1: {2: 6}},
+		},
+		{
+			name:     "Opposite debts cancel out",
+			input:    debtAmounts{1: {2: 500}, 2: {1: 500}},
+			expected: debtAmounts{},
+		},
+		{
+			name:     "Zero and empty entries are dropped",
+			input:    debtAmounts{1: {2: 0}, 3: {}},
+			expected: debtAmounts{},
+		},
+		{
+			name: "Acumulated map from several movements",
+			input: debtAmounts{
+				1: {2: 1500},
+				2: {1: 1200},
+				3: {1: 800, 2: 100},
+				4: {1: 100, 2: 200},
+			},
+			expected: debtAmounts{
+				1: {2: 300},
+				3: {1: 800, 2: 100},
+				4: {1: 100, 2: 200},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			generated, err := NetDebitCreditMap(test.input.toDebitCreditMap())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !areEquals(generated, test.expected) {
+				t.Errorf("generated %v, expected %v", generated, test.expected)
+			}
+		})
+	}
+}
+
+func areEquals(left DebitCreditMap, expected debtAmounts) bool {
+	right := expected.toDebitCreditMap()
 	if len(left) != len(right) {
 		fmt.Printf("Length mismatch: left=%d, right=%d\n", len(left), len(right))
 		return false
@@ -499,7 +734,7 @@ func areEquals(left, right DebitCreditMap) bool {
 				return false
 			}
 			if leftValue != rightValue {
-				fmt.Printf("Value mismatch at key %d -> %d: left=%d, right=%d\n", key, innerKey, leftValue, rightValue)
+				fmt.Printf("Value mismatch at key %d -> %d: left=%v, right=%v\n", key, innerKey, leftValue, rightValue)
 				return false
 			}
 		}
@@ -511,21 +746,21 @@ func areEquals(left, right DebitCreditMap) bool {
 func TestSumParticipantShares(t *testing.T) {
 	tests := []struct {
 		name     string
-		left     ParticipantShareByParticipantId
-		right    ParticipantShareByParticipantId
-		expected ParticipantShareByParticipantId
+		left     shareAmounts
+		right    shareAmounts
+		expected shareAmounts
 	}{
 		{
 			name: "Merges two maps with no overlapping keys",
-			left: ParticipantShareByParticipantId{
+			left: shareAmounts{
 				1: 100,
 				2: 200,
 			},
-			right: ParticipantShareByParticipantId{
+			right: shareAmounts{
 				3: 300,
 				4: 400,
 			},
-			expected: ParticipantShareByParticipantId{
+			expected: shareAmounts{
 				1: 100,
 				2: 200,
 				3: 300,
@@ -534,15 +769,15 @@ func TestSumParticipantShares(t *testing.T) {
 		},
 		{
 			name: "Adds values for overlapping keys",
-			left: ParticipantShareByParticipantId{
+			left: shareAmounts{
 				1: 100,
 				2: 200,
 			},
-			right: ParticipantShareByParticipantId{
+			right: shareAmounts{
 				2: 150,
 				3: 300,
 			},
-			expected: ParticipantShareByParticipantId{
+			expected: shareAmounts{
 				1: 100, // Solo en `left`
 				2: 350, // 200 (left) + 150 (right)
 				3: 300, // Solo en `right`
@@ -550,32 +785,76 @@ func TestSumParticipantShares(t *testing.T) {
 		},
 		{
 			name:     "Handles empty left map",
-			left:     ParticipantShareByParticipantId{},
-			right:    ParticipantShareByParticipantId{1: 100},
-			expected: ParticipantShareByParticipantId{1: 100}, // Igual a `right`
+			left:     shareAmounts{},
+			right:    shareAmounts{1: 100},
+			expected: shareAmounts{1: 100}, // Igual a `right`
 		},
 		{
 			name:     "Handles empty right map",
-			left:     ParticipantShareByParticipantId{1: 100},
-			right:    ParticipantShareByParticipantId{},
-			expected: ParticipantShareByParticipantId{1: 100}, // Igual a `left`
+			left:     shareAmounts{1: 100},
+			right:    shareAmounts{},
+			expected: shareAmounts{1: 100}, // Igual a `left`
 		},
 		{
 			name:     "Both maps are empty",
-			left:     ParticipantShareByParticipantId{},
-			right:    ParticipantShareByParticipantId{},
-			expected: ParticipantShareByParticipantId{}, // Resultado vacío
+			left:     shareAmounts{},
+			right:    shareAmounts{},
+			expected: shareAmounts{}, // Resultado vacío
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			result := SumParticipantShares(tc.left, tc.right)
+			result, err := SumParticipantShares(tc.left.toShares(), tc.right.toShares())
+			if err != nil {
+				t.Fatal(err.Error())
+			}
 
-			if !reflect.DeepEqual(result, tc.expected) {
+			if !reflect.DeepEqual(result, tc.expected.toShares()) {
 				t.Errorf("Failed %s:\nGot:      %v\nExpected: %v", tc.name, result, tc.expected)
 			}
 
 		})
 	}
 }
+
+func TestSharesAndDebitCreditMapsRefuseToMixCurrencies(t *testing.T) {
+	pesos := ParticipantShareByParticipantId{1: ars(500), 2: ars(-500)}
+	dollars := ParticipantShareByParticipantId{1: NewMoney(-10, "USD"), 2: NewMoney(10, "USD")}
+	mixed := ParticipantShareByParticipantId{1: ars(500), 2: NewMoney(-500, "USD")}
+
+	_, err := SumParticipantShares(pesos, dollars)
+	if err != ErrCurrencyMismatch {
+		t.Errorf("summing shares: got error %v, expected %v", err, ErrCurrencyMismatch)
+	}
+	err = EnsureSharesSumToZero(mixed)
+	if err != ErrCurrencyMismatch {
+		t.Errorf("ensuring shares sum to zero: got error %v, expected %v", err, ErrCurrencyMismatch)
+	}
+	_, err = BuildProportionalDebitCreditMap(mixed)
+	if err != ErrCurrencyMismatch {
+		t.Errorf("building the debit credit map: got error %v, expected %v", err, ErrCurrencyMismatch)
+	}
+
+	pesosDebts, err := BuildProportionalDebitCreditMap(pesos)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	dollarsDebts, err := BuildProportionalDebitCreditMap(dollars)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = SumDebitCreditMaps(pesosDebts, dollarsDebts)
+	if err != ErrCurrencyMismatch {
+		t.Errorf("summing debit credit maps: got error %v, expected %v", err, ErrCurrencyMismatch)
+	}
+	_, err = NetDebitCreditMap(DebitCreditMap{1: {2: ars(10)}, 2: {1: NewMoney(4, "USD")}})
+	if err != ErrCurrencyMismatch {
+		t.Errorf("netting debit credit maps: got error %v, expected %v", err, ErrCurrencyMismatch)
+	}
+
+	acumulated, err := SumParticipantShares(make(ParticipantShareByParticipantId), dollars)
+	if err != nil || !reflect.DeepEqual(acumulated, dollars) {
+		t.Errorf("summing to no shares: got %v (error %v), expected %v", acumulated, err, dollars)
+	}
+}
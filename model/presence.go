@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// The days a participant spent with the group (e.g. on a trip), from the arrival day to the departure day both inclusive
+type PresenceInterval struct {
+	From int64 `json:"from"` // unix timestamp of the arrival day
+	To   int64 `json:"to"`   // unix timestamp of the departure day
+}
+
+var ErrInvalidPresenceInterval error = errors.New("A presence interval must not end before it starts")
+var ErrOverlappingPresenceIntervals error = errors.New("The presence intervals of a participant must not share nights")
+var ErrInvalidMovementPeriod error = errors.New("A movement period must have a start and must end after it starts")
+var ErrNobodyPresent error = errors.New("None of the movement's beneficiaries was present when the movement took place")
+
+// The intervals may touch (e.g. leaving and coming back the same day) but not overlap, as the nights they share would be counted
+// twice by GetPresenceWeight
+func EnsurePresenceIntervalsAreValid(presences []PresenceInterval) error {
+	for _, presence := range presences {
+		if toDay(presence.To).Before(toDay(presence.From)) {
+			return ErrInvalidPresenceInterval
+		}
+	}
+	sorted := append([]PresenceInterval(nil), presences...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].From < sorted[j].From
+	})
+	for i := 1; i < len(sorted); i++ {
+		if toDay(sorted[i].From).Before(toDay(sorted[i-1].To)) {
+			return ErrOverlappingPresenceIntervals
+		}
+	}
+	return nil
+}
+
+// the day (at UTC midnight) of the given unix timestamp
+func toDay(timestamp int64) time.Time {
+	return time.Unix(timestamp, 0).UTC().Truncate(24 * time.Hour)
+}
+
+func countNights(from time.Time, to time.Time) int {
+	if !to.After(from) {
+		return 0
+	}
+	return int(to.Sub(from) / (24 * time.Hour))
+}
+
+// How much the participant takes part of a movement split by presence: for a movement dated on a single day it is one when the
+// participant was present that day, for a movement spanning a period it is the number of nights the participant spent within it.
+// Participants without presence intervals are taken as present all along.
+func (participant Participant) GetPresenceWeight(movement Movement) int {
+	if len(participant.Presences) == 0 {
+		if movement.PeriodTo == 0 {
+			return 1
+		}
+		return countNights(toDay(movement.PeriodFrom), toDay(movement.PeriodTo))
+	}
+	weight := 0
+	for _, presence := range participant.Presences {
+		arrival, departure := toDay(presence.From), toDay(presence.To)
+		if movement.PeriodTo == 0 {
+			day := toDay(movement.GetDate())
+			if !day.Before(arrival) && !day.After(departure) {
+				return 1
+			}
+			continue
+		}
+		from, to := toDay(movement.PeriodFrom), toDay(movement.PeriodTo)
+		if arrival.After(from) {
+			from = arrival
+		}
+		if departure.Before(to) {
+			to = departure
+		}
+		weight += countNights(from, to)
+	}
+	return weight
+}
+
+// the day a movement took place, its period start when it has one or else the day it was entered
+func (movement Movement) GetDate() int64 {
+	if movement.PeriodFrom != 0 {
+		return movement.PeriodFrom
+	}
+	return movement.CreatedAt
+}
+
+// Sets the weight of each beneficiary to how much they were present when the movement took place (see GetPresenceWeight),
+// payers that are not beneficiaries get no weight
+func BuildParticipantsPresenceWeights(movement Movement, participantMovements []ParticipantMovement, participants []Participant) ([]ParticipantMovement, error) {
+	if movement.PeriodTo != 0 && (movement.PeriodFrom == 0 || !toDay(movement.PeriodTo).After(toDay(movement.PeriodFrom))) {
+		return nil, ErrInvalidMovementPeriod
+	}
+	participantById := make(map[int]Participant)
+	for _, participant := range participants {
+		participantById[participant.Id] = participant
+	}
+	weighted := make([]ParticipantMovement, 0, len(participantMovements))
+	totalWeight := 0
+	for _, participantMovement := range participantMovements {
+		participantMovement.Weight = 0
+		if participantMovement.IsBeneficiary() {
+			participantMovement.Weight = participantById[participantMovement.ParticipantId].GetPresenceWeight(movement)
+		}
+		totalWeight += participantMovement.Weight
+		weighted = append(weighted, participantMovement)
+	}
+	if totalWeight == 0 {
+		return nil, ErrNobodyPresent
+	}
+	return weighted, nil
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(month time.Month, dayOfMonth int) int64 {
+	return time.Date(2024, month, dayOfMonth, 15, 0, 0, 0, time.UTC).Unix()
+}
+
+func TestBuildParticipantsPresenceWeights(t *testing.T) {
+	participants := []Participant{
+		{Id: 1, Presences: []PresenceInterval{{From: day(1, 1), To: day(1, 8)}}},
+		{Id: 2, Presences: []PresenceInterval{{From: day(1, 4), To: day(1, 8)}}},
+		{Id: 3, Presences: []PresenceInterval{{From: day(1, 1), To: day(1, 3)}, {From: day(1, 6), To: day(1, 7)}}},
+		{Id: 4},
+	}
+	participantMovements := []ParticipantMovement{
+		{ParticipantId: 1, Amount: 7000},
+		{ParticipantId: 2, Amount: 0},
+		{ParticipantId: 3, Amount: 0},
+		{ParticipantId: 4, Amount: 0},
+	}
+	tests := []struct {
+		name            string
+		movement        Movement
+		expectedWeights []int
+		expectedError   error
+	}{
+		{
+			name:            "Week long rental weighted by nights",
+			movement:        Movement{PeriodFrom: day(1, 1), PeriodTo: day(1, 8)},
+			expectedWeights: []int{7, 4, 3, 7},
+		},
+		{
+			name:            "Dinner on a single day",
+			movement:        Movement{PeriodFrom: day(1, 3)},
+			expectedWeights: []int{1, 0, 1, 1},
+		},
+		{
+			name:            "Dated by the day it was entered",
+			movement:        Movement{CreatedAt: day(1, 5)},
+			expectedWeights: []int{1, 1, 0, 1},
+		},
+		{
+			name:          "Period ending before it starts",
+			movement:      Movement{PeriodFrom: day(1, 8), PeriodTo: day(1, 1)},
+			expectedError: ErrInvalidMovementPeriod,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weighted, err := BuildParticipantsPresenceWeights(test.movement, participantMovements, participants)
+			if err != test.expectedError {
+				t.Fatalf("got error %v, expected %v", err, test.expectedError)
+			}
+			if err != nil {
+				return
+			}
+			weights := make([]int, 0, len(weighted))
+			for _, participantMovement := range weighted {
+				weights = append(weights, participantMovement.Weight)
+			}
+			if !reflect.DeepEqual(weights, test.expectedWeights) {
+				t.Errorf("got weights %v, expected %v", weights, test.expectedWeights)
+			}
+		})
+	}
+
+	_, err := BuildParticipantsPresenceWeights(Movement{PeriodFrom: day(2, 1)}, participantMovements[:3], participants)
+	if err != ErrNobodyPresent {
+		t.Errorf("got error %v, expected %v", err, ErrNobodyPresent)
+	}
+}
+
+func TestEnsurePresenceIntervalsAreValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		presences     []PresenceInterval
+		expectedError error
+	}{
+		{
+			name:      "Separate stays",
+			presences: []PresenceInterval{{From: day(1, 6), To: day(1, 7)}, {From: day(1, 1), To: day(1, 3)}},
+		},
+		{
+			name:      "Leaving and coming back the same day",
+			presences: []PresenceInterval{{From: day(1, 1), To: day(1, 3)}, {From: day(1, 3), To: day(1, 7)}},
+		},
+		{
+			name:          "Ending before it starts",
+			presences:     []PresenceInterval{{From: day(1, 3), To: day(1, 1)}},
+			expectedError: ErrInvalidPresenceInterval,
+		},
+		{
+			name:          "Overlapping stays",
+			presences:     []PresenceInterval{{From: day(1, 4), To: day(1, 7)}, {From: day(1, 1), To: day(1, 5)}},
+			expectedError: ErrOverlappingPresenceIntervals,
+		},
+		{
+			name:          "Duplicated stay",
+			presences:     []PresenceInterval{{From: day(1, 1), To: day(1, 3)}, {From: day(1, 1), To: day(1, 3)}},
+			expectedError: ErrOverlappingPresenceIntervals,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := EnsurePresenceIntervalsAreValid(test.presences)
+			if err != test.expectedError {
+				t.Errorf("got error %v, expected %v", err, test.expectedError)
+			}
+		})
+	}
+}
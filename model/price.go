@@ -1,3 +1,7 @@
 package model
 
+// an amount of money expressed in the minor units (e.g. cents) of some currency, see Money for an amount that knows its currency.
+// The entities keep their amounts as prices along with the currency of the movement they belong to, while the shares, debts,
+// balances and settlement transfers built by the model are Money and the functions combining them refuse to mix currencies
+// (see ErrCurrencyMismatch), so the api converts every movement to its group's currency (see ConvertMovementToCurrency) first.
 type Price = int
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Gives the value of a monthly price index (e.g. the CPI) for the month of the given moment
+type PriceIndexProvider interface {
+	GetIndex(at time.Time) (float64, error)
+}
+
+var ErrPriceIndexNotFound error = errors.New("There is no price index for the given month")
+var ErrInvalidPriceIndex error = errors.New("The price index must be greater than zero")
+var ErrPriceIndexNotConfigured error = errors.New("Inflation adjustment needs a price index and none is configured")
+
+// How much the prices went up between both moments, e.g. 1.25 when what cost 100 at "from" costs 125 at "to"
+func BuildInflationAdjustmentFactor(provider PriceIndexProvider, from time.Time, to time.Time) (float64, error) {
+	fromIndex, err := provider.GetIndex(from)
+	if err != nil {
+		return 0, err
+	}
+	toIndex, err := provider.GetIndex(to)
+	if err != nil {
+		return 0, err
+	}
+	if fromIndex <= 0 || toIndex <= 0 {
+		return 0, ErrInvalidPriceIndex
+	}
+	return toIndex / fromIndex, nil
+}
+
+// Scales the shares by the factor, the adjusted credit is rounded once and then distributed among creditors and debtors in proportion
+// to their nominal shares so the adjusted shares still sum to zero
+func AdjustShares(shares ParticipantShareByParticipantId, factor float64) (ParticipantShareByParticipantId, error) {
+	return scaleShares(shares, func(totalCredit Price) Price {
+		return Price(math.Round(float64(totalCredit) * factor))
+	})
+}
+
+// Distributes the scaled total credit among creditors and, negated, among debtors in proportion to their shares
+func scaleShares(participantShares ParticipantShareByParticipantId, scaleTotalCredit func(totalCredit Price) Price) (ParticipantShareByParticipantId, error) {
+	currency, err := participantShares.getCurrency()
+	if err != nil {
+		return nil, err
+	}
+	shares := participantShares.getAmounts()
+	creditByParticipantId := make(map[int]int)
+	debtByParticipantId := make(map[int]int)
+	totalCredit := 0
+	for participantId, share := range shares {
+		if share > 0 {
+			creditByParticipantId[participantId] = share
+			totalCredit += share
+		} else if share < 0 {
+			debtByParticipantId[participantId] = -share
+		}
+	}
+
+	scaledShares := make(map[int]Price)
+	for participantId := range shares {
+		scaledShares[participantId] = 0
+	}
+	if totalCredit == 0 {
+		return newParticipantShares(scaledShares, currency), nil
+	}
+	scaledTotalCredit := scaleTotalCredit(totalCredit)
+	sortRemainderRecipients, _ := LargestRemainderPolicy.remainderRecipientsSorter(Movement{}, nil)
+	scaledCredits, _ := distributeProportionally(scaledTotalCredit, creditByParticipantId, sortRemainderRecipients)
+	scaledDebts, _ := distributeProportionally(scaledTotalCredit, debtByParticipantId, sortRemainderRecipients)
+	for participantId, credit := range scaledCredits {
+		scaledShares[participantId] = credit
+	}
+	for participantId, debt := range scaledDebts {
+		scaledShares[participantId] = -debt
+	}
+	return newParticipantShares(scaledShares, currency), nil
+}
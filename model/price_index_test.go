@@ -0,0 +1,79 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type monthlyPriceIndex map[string]float64
+
+func (index monthlyPriceIndex) GetIndex(at time.Time) (float64, error) {
+	value, exists := index[at.Format("2006-01")]
+	if !exists {
+		return 0, ErrPriceIndexNotFound
+	}
+	return value, nil
+}
+
+func TestBuildInflationAdjustmentFactor(t *testing.T) {
+	index := monthlyPriceIndex{"2024-01": 200, "2024-07": 300}
+	factor, err := BuildInflationAdjustmentFactor(index, time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || factor != 1.5 {
+		t.Errorf("got factor %v (error %v), expected 1.5", factor, err)
+	}
+	_, err = BuildInflationAdjustmentFactor(index, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	if err != ErrPriceIndexNotFound {
+		t.Errorf("got error %v, expected %v", err, ErrPriceIndexNotFound)
+	}
+}
+
+func TestAdjustShares(t *testing.T) {
+	tests := []struct {
+		name     string
+		shares   shareAmounts
+		factor   float64
+		expected shareAmounts
+	}{
+		{
+			name:     "Without inflation nothing changes",
+			shares:   shareAmounts{1: 600, 2: -400, 3: -200},
+			factor:   1,
+			expected: shareAmounts{1: 600, 2: -400, 3: -200},
+		},
+		{
+			name:     "Exact scaling",
+			shares:   shareAmounts{1: 600, 2: -400, 3: -200},
+			factor:   1.5,
+			expected: shareAmounts{1: 900, 2: -600, 3: -300},
+		},
+		{
+			name:     "Rounding keeps the sum at zero",
+			shares:   shareAmounts{1: 100, 2: -50, 3: -50},
+			factor:   1.333,
+			expected: shareAmounts{1: 133, 2: -67, 3: -66},
+		},
+		{
+			name:     "Everybody even",
+			shares:   shareAmounts{1: 0, 2: 0},
+			factor:   2,
+			expected: shareAmounts{1: 0, 2: 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			adjusted, err := AdjustShares(test.shares.toShares(), test.factor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(adjusted, test.expected.toShares()) {
+				t.Fatalf("adjusted %v, expected %v", adjusted, test.expected)
+			}
+			err = EnsureSharesSumToZero(adjusted)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+)
+
+// Decides who takes the units that are left over when an amount can not be evenly split (e.g. 100 among 3)
+type RemainderPolicy string
+
+const (
+	LargestRemainderPolicy RemainderPolicy = "largestRemainder" // one unit each to the participants whose exact part lost the most, ties broken by participant id
+	LargestPayerPolicy     RemainderPolicy = "largestPayer"     // the candidate that paid the most absorbs all the units, ties broken by participant id, as largest remainder when no candidate paid
+	RoundRobinPolicy       RemainderPolicy = "roundRobin"       // one unit each by participant id, starting at an offset that moves with the movement id
+	SeededRandomPolicy     RemainderPolicy = "seededRandom"     // one unit each in a pseudo random order seeded by the movement id
+
+	DefaultRemainderPolicy = LargestRemainderPolicy
+)
+
+var ErrUnknownRemainderPolicy error = errors.New("The remainder policy is not supported")
+
+func EnsureRemainderPolicyIsSupported(policy RemainderPolicy) error {
+	switch policy {
+	case "", LargestRemainderPolicy, LargestPayerPolicy, RoundRobinPolicy, SeededRandomPolicy:
+		return nil
+	default:
+		return ErrUnknownRemainderPolicy
+	}
+}
+
+// Sorts the candidates (given sorted by id) to receive the left over units, the first ones receive a unit first
+type remainderRecipientsSorter func(candidateIds []int, remainderByParticipantId map[int]int) []int
+
+func (policy RemainderPolicy) remainderRecipientsSorter(movement Movement, participantMovements []ParticipantMovement) (remainderRecipientsSorter, error) {
+	switch policy {
+	case "", LargestRemainderPolicy:
+		return func(candidateIds []int, remainderByParticipantId map[int]int) []int {
+			recipientIds := append([]int{}, candidateIds...)
+			sort.SliceStable(recipientIds, func(i, j int) bool {
+				return remainderByParticipantId[recipientIds[i]] > remainderByParticipantId[recipientIds[j]]
+			})
+			return recipientIds
+		}, nil
+	case LargestPayerPolicy:
+		paidByParticipantId := make(map[int]Price)
+		for _, participantMovement := range participantMovements {
+			paidByParticipantId[participantMovement.ParticipantId] += participantMovement.Amount
+		}
+		largestRemainderSorter, _ := LargestRemainderPolicy.remainderRecipientsSorter(movement, participantMovements)
+		return func(candidateIds []int, remainderByParticipantId map[int]int) []int {
+			largestPayerId, largestPaid := 0, 0
+			for _, candidateId := range candidateIds { // sorted by id, so ties go to the lowest id
+				if paidByParticipantId[candidateId] > largestPaid {
+					largestPayerId, largestPaid = candidateId, paidByParticipantId[candidateId]
+				}
+			}
+			if largestPaid == 0 { // none of the candidates paid
+				return largestRemainderSorter(candidateIds, remainderByParticipantId)
+			}
+			return []int{largestPayerId}
+		}, nil
+	case RoundRobinPolicy:
+		return func(candidateIds []int, remainderByParticipantId map[int]int) []int {
+			offset := movement.Id % len(candidateIds)
+			return append(append([]int{}, candidateIds[offset:]...), candidateIds[:offset]...)
+		}, nil
+	case SeededRandomPolicy:
+		return func(candidateIds []int, remainderByParticipantId map[int]int) []int {
+			random := rand.New(rand.NewSource(int64(movement.Id)))
+			recipientIds := make([]int, len(candidateIds))
+			for i, j := range random.Perm(len(candidateIds)) {
+				recipientIds[i] = candidateIds[j]
+			}
+			return recipientIds
+		}, nil
+	default:
+		return nil, ErrUnknownRemainderPolicy
+	}
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemainderPoliciesKeepSharesSumToZero(t *testing.T) {
+	paidBySecond := func(amount Price) []ParticipantMovement {
+		return []ParticipantMovement{
+			{ParticipantId: 1, MovementId: 2, Amount: 0},
+			{ParticipantId: 2, MovementId: 2, Amount: amount},
+			{ParticipantId: 3, MovementId: 2, Amount: 0},
+		}
+	}
+	tests := []struct {
+		name           string
+		movement       Movement
+		policy         RemainderPolicy
+		expectedShares shareAmounts
+	}{
+		{
+			name:           "Largest remainder (default), ties broken by participant id",
+			movement:       Movement{Id: 2, Amount: 100},
+			policy:         "",
+			expectedShares: shareAmounts{1: -34, 2: 67, 3: -33},
+		},
+		{
+			name:           "Largest payer absorbs the remainder",
+			movement:       Movement{Id: 2, Amount: 100},
+			policy:         LargestPayerPolicy,
+			expectedShares: shareAmounts{1: -33, 2: 66, 3: -33},
+		},
+		{
+			name:           "Round robin starts at the participant given by the movement id",
+			movement:       Movement{Id: 2, Amount: 100},
+			policy:         RoundRobinPolicy,
+			expectedShares: shareAmounts{1: -33, 2: 67, 3: -34},
+		},
+		{
+			name:           "Round robin with two units left over",
+			movement:       Movement{Id: 2, Amount: 101},
+			policy:         RoundRobinPolicy,
+			expectedShares: shareAmounts{1: -34, 2: 68, 3: -34},
+		},
+		{
+			name:           "Largest payer absorbs two units left over",
+			movement:       Movement{Id: 2, Amount: 101},
+			policy:         LargestPayerPolicy,
+			expectedShares: shareAmounts{1: -33, 2: 66, 3: -33},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, err := BuildParticipantsShare(test.movement, paidBySecond(test.movement.Amount), test.policy)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			err = EnsureSharesSumToZero(shares)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(shares, test.expectedShares.toShares()) {
+				t.Errorf("generated share %v, expected share %v", shares, test.expectedShares)
+			}
+		})
+	}
+}
+
+func TestSeededRandomRemainderPolicyIsDeterministic(t *testing.T) {
+	participantMovements := []ParticipantMovement{
+		{ParticipantId: 1, MovementId: 7, Amount: 100},
+		{ParticipantId: 2, MovementId: 7, Amount: 0},
+		{ParticipantId: 3, MovementId: 7, Amount: 0},
+	}
+	movement := Movement{Id: 7, Amount: 100}
+	shares, err := BuildParticipantsShare(movement, participantMovements, SeededRandomPolicy)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = EnsureSharesSumToZero(shares)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := 0; i < 10; i++ {
+		again, _ := BuildParticipantsShare(movement, participantMovements, SeededRandomPolicy)
+		if !reflect.DeepEqual(shares, again) {
+			t.Fatalf("generated share %v differs from first generated share %v", again, shares)
+		}
+	}
+}
+
+func TestUnknownRemainderPolicyIsRejected(t *testing.T) {
+	participantMovements := []ParticipantMovement{
+		{ParticipantId: 1, MovementId: 1, Amount: 100},
+		{ParticipantId: 2, MovementId: 1, Amount: 0},
+	}
+	_, err := BuildParticipantsShare(Movement{Id: 1, Amount: 100}, participantMovements, "whatever")
+	if err != ErrUnknownRemainderPolicy {
+		t.Fatalf("generated error %v, expected error %v", err, ErrUnknownRemainderPolicy)
+	}
+	if EnsureRemainderPolicyIsSupported("whatever") != ErrUnknownRemainderPolicy {
+		t.Fatalf("expected policy 'whatever' not to be supported")
+	}
+}
+
+func TestLargestPayerPolicyOnlyChoosesAmongCandidates(t *testing.T) {
+	movement := Movement{Id: 1, Amount: 301, SplitStrategy: ItemizedSplit, Items: []MovementItem{
+		{Concept: "Pan", Amount: 100, ParticipantIds: []int{1}},
+		{Concept: "Vino", Amount: 201, ParticipantIds: []int{2, 3}},
+	}}
+	tests := []struct {
+		name                 string
+		participantMovements []ParticipantMovement
+		expectedShares       shareAmounts
+	}{
+		{
+			name: "The largest payer is not on the item, so its remainder follows the largest remainder",
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, Amount: 301},
+				{ParticipantId: 2, Amount: 0},
+				{ParticipantId: 3, Amount: 0},
+			},
+			expectedShares: shareAmounts{1: 201, 2: -101, 3: -100},
+		},
+		{
+			name: "The largest payer among the item's participants absorbs its remainder",
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, Amount: 201},
+				{ParticipantId: 2, Amount: 0},
+				{ParticipantId: 3, Amount: 100},
+			},
+			expectedShares: shareAmounts{1: 101, 2: -100, 3: -1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, err := BuildParticipantsShare(movement, test.participantMovements, LargestPayerPolicy)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(shares, test.expectedShares.toShares()) {
+				t.Errorf("generated share %v, expected share %v", shares, test.expectedShares)
+			}
+		})
+	}
+}
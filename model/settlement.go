@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"sort"
+)
+
+// a suggested payment that moves the group towards everybody being even
+type SettlementTransfer struct {
+	FromParticipantId int   `json:"fromParticipantId"`
+	ToParticipantId   int   `json:"toParticipantId"`
+	Amount            Money `json:"amount"`
+}
+
+// Builds the transfers that settle the accumulated shares, greedily matching the largest debtor with the largest creditor
+// (ties broken by participant id), so every transfer leaves at least one of them even and there are at most N-1 transfers.
+func BuildSettlementTransfers(shares ParticipantShareByParticipantId) ([]SettlementTransfer, error) {
+	currency, err := shares.getCurrency()
+	if err != nil {
+		return nil, err
+	}
+	debtByParticipantId := make(map[int]Price)
+	creditByParticipantId := make(map[int]Price)
+	for id, share := range shares.getAmounts() {
+		if share < 0 {
+			debtByParticipantId[id] = -share
+		} else if share > 0 {
+			creditByParticipantId[id] = share
+		}
+	}
+
+	transfers := []SettlementTransfer{}
+	for len(debtByParticipantId) > 0 && len(creditByParticipantId) > 0 {
+		debtorId := getLargestAmountParticipantId(debtByParticipantId)
+		creditorId := getLargestAmountParticipantId(creditByParticipantId)
+		amount := debtByParticipantId[debtorId]
+		if creditByParticipantId[creditorId] < amount {
+			amount = creditByParticipantId[creditorId]
+		}
+		transfers = append(transfers, SettlementTransfer{FromParticipantId: debtorId, ToParticipantId: creditorId, Amount: NewMoney(amount, currency)})
+
+		debtByParticipantId[debtorId] -= amount
+		if debtByParticipantId[debtorId] == 0 {
+			delete(debtByParticipantId, debtorId)
+		}
+		creditByParticipantId[creditorId] -= amount
+		if creditByParticipantId[creditorId] == 0 {
+			delete(creditByParticipantId, creditorId)
+		}
+	}
+	return transfers, nil
+}
+
+func getLargestAmountParticipantId(amountByParticipantId map[int]Price) int {
+	participantIds := make([]int, 0, len(amountByParticipantId))
+	for id := range amountByParticipantId {
+		participantIds = append(participantIds, id)
+	}
+	sort.Ints(participantIds)
+	largestId := participantIds[0]
+	for _, id := range participantIds[1:] {
+		if amountByParticipantId[id] > amountByParticipantId[largestId] {
+			largestId = id
+		}
+	}
+	return largestId
+}
+
+var ErrSettlementNotSuggested error = errors.New("The settlement transfer is not among the suggested ones")
+var ErrInvalidSettlementAmount error = errors.New("The settled amount must be positive and must not exceed the suggested amount")
+var ErrSharesNotSettled error = errors.New("Every participant's share must be zero once settled")
+
+// Ensures the transfer pays (part of) one of the suggested transfers, in its currency
+func EnsureSettlementTransferIsSuggested(transfer SettlementTransfer, suggestedTransfers []SettlementTransfer) error {
+	for _, suggestedTransfer := range suggestedTransfers {
+		if suggestedTransfer.FromParticipantId == transfer.FromParticipantId && suggestedTransfer.ToParticipantId == transfer.ToParticipantId {
+			if transfer.Amount.Currency != suggestedTransfer.Amount.Currency {
+				return ErrCurrencyMismatch
+			}
+			if transfer.Amount.Amount <= 0 || transfer.Amount.Amount > suggestedTransfer.Amount.Amount {
+				return ErrInvalidSettlementAmount
+			}
+			return nil
+		}
+	}
+	return ErrSettlementNotSuggested
+}
+
+// invariante de que 0 = participantShareByParticipantId[i] para todo i
+func EnsureSharesAreSettled(participantShareByParticipantId ParticipantShareByParticipantId) error {
+	for _, share := range participantShareByParticipantId {
+		if !share.IsZero() {
+			return ErrSharesNotSettled
+		}
+	}
+	return nil
+}
+
+// the debtor gives the amount to the creditor, so the debtor's debt and the creditor's credit decrease by it
+func BuildSettlementTransferMovement(transfer SettlementTransfer, movement Movement) TransferMovement {
+	movement.Amount = transfer.Amount.Amount
+	movement.Currency = transfer.Amount.Currency
+	movement.Kind = TransferKind
+	return TransferMovement{
+		Movement:          movement,
+		FromParticipantId: transfer.FromParticipantId,
+		ToParticipantId:   transfer.ToParticipantId,
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildSettlementTransfers(t *testing.T) {
+	tests := []struct {
+		name     string
+		shares   shareAmounts
+		expected []SettlementTransfer
+	}{
+		{
+			name:     "Everybody even, nothing to settle",
+			shares:   shareAmounts{1: 0, 2: 0},
+			expected: []SettlementTransfer{},
+		},
+		{
+			name:   "One debtor, one creditor",
+			shares: shareAmounts{1: 500, 2: -500},
+			expected: []SettlementTransfer{
+				{FromParticipantId: 2, ToParticipantId: 1, Amount: ars(500)},
+			},
+		},
+		{
+			name:   "Criss-crossing debts collapse into two transfers",
+			shares: shareAmounts{1: 600, 2: -400, 3: -200},
+			expected: []SettlementTransfer{
+				{FromParticipantId: 2, ToParticipantId: 1, Amount: ars(400)},
+				{FromParticipantId: 3, ToParticipantId: 1, Amount: ars(200)},
+			},
+		},
+		{
+			name:   "Largest debtor pays largest creditor first",
+			shares: shareAmounts{1: 300, 2: 700, 3: -800, 4: -100, 5: -100},
+			expected: []SettlementTransfer{
+				{FromParticipantId: 3, ToParticipantId: 2, Amount: ars(700)},
+				{FromParticipantId: 3, ToParticipantId: 1, Amount: ars(100)},
+				{FromParticipantId: 4, ToParticipantId: 1, Amount: ars(100)},
+				{FromParticipantId: 5, ToParticipantId: 1, Amount: ars(100)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			generated, err := BuildSettlementTransfers(newParticipantShares(test.shares, "ARS"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(generated, test.expected) {
+				t.Fatalf("generated %v, expected %v", generated, test.expected)
+			}
+
+			settledShares := make(map[int]Price)
+			for id, share := range test.shares {
+				settledShares[id] = share
+			}
+			for _, transfer := range generated {
+				settledShares[transfer.FromParticipantId] += transfer.Amount.Amount
+				settledShares[transfer.ToParticipantId] -= transfer.Amount.Amount
+			}
+			for id, share := range settledShares {
+				if share != 0 {
+					t.Errorf("participant %d still has share %d after settling", id, share)
+				}
+			}
+		})
+	}
+}
+
+func TestEnsureSettlementTransferIsSuggested(t *testing.T) {
+	suggestedTransfers := []SettlementTransfer{
+		{FromParticipantId: 2, ToParticipantId: 1, Amount: ars(400)},
+	}
+	tests := []struct {
+		name     string
+		transfer SettlementTransfer
+		expected error
+	}{
+		{
+			name:     "Full payment",
+			transfer: SettlementTransfer{FromParticipantId: 2, ToParticipantId: 1, Amount: ars(400)},
+			expected: nil,
+		},
+		{
+			name:     "Partial payment",
+			transfer: SettlementTransfer{FromParticipantId: 2, ToParticipantId: 1, Amount: ars(150)},
+			expected: nil,
+		},
+		{
+			name:     "Paying more than suggested",
+			transfer: SettlementTransfer{FromParticipantId: 2, ToParticipantId: 1, Amount: ars(401)},
+			expected: ErrInvalidSettlementAmount,
+		},
+		{
+			name:     "Paying nothing",
+			transfer: SettlementTransfer{FromParticipantId: 2, ToParticipantId: 1, Amount: ars(0)},
+			expected: ErrInvalidSettlementAmount,
+		},
+		{
+			name:     "Paying in another currency",
+			transfer: SettlementTransfer{FromParticipantId: 2, ToParticipantId: 1, Amount: NewMoney(400, "USD")},
+			expected: ErrCurrencyMismatch,
+		},
+		{
+			name:     "Paying in the wrong direction",
+			transfer: SettlementTransfer{FromParticipantId: 1, ToParticipantId: 2, Amount: ars(400)},
+			expected: ErrSettlementNotSuggested,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := EnsureSettlementTransferIsSuggested(test.transfer, suggestedTransfers)
+			if err != test.expected {
+				t.Fatalf("generated error %v, expected error %v", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestBuildSettlementTransfersRefusesToMixCurrencies(t *testing.T) {
+	shares := ParticipantShareByParticipantId{1: ars(500), 2: NewMoney(-500, "USD")}
+	_, err := BuildSettlementTransfers(shares)
+	if err != ErrCurrencyMismatch {
+		t.Fatalf("generated error %v, expected error %v", err, ErrCurrencyMismatch)
+	}
+}
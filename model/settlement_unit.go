@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"sort"
+)
+
+// Participants that settle as a single party (e.g. a couple sharing a bank account), the first one pays and receives on behalf of the unit
+type SettlementUnit struct {
+	Name           string `json:"name"`
+	ParticipantIds []int  `json:"participantIds"`
+}
+
+func (unit SettlementUnit) GetRepresentativeId() int {
+	return unit.ParticipantIds[0]
+}
+
+var ErrInvalidSettlementUnits error = errors.New("Every settlement unit must have participants and a participant can not belong to more than one unit")
+
+func EnsureSettlementUnitsAreValid(units []SettlementUnit) error {
+	unitByParticipantId := make(map[int]int)
+	for i, unit := range units {
+		if len(unit.ParticipantIds) == 0 {
+			return ErrInvalidSettlementUnits
+		}
+		for _, participantId := range unit.ParticipantIds {
+			if _, exists := unitByParticipantId[participantId]; exists {
+				return ErrInvalidSettlementUnits
+			}
+			unitByParticipantId[participantId] = i
+		}
+	}
+	return nil
+}
+
+// The aggregated share of a settlement unit along with the share of each of its members
+type SettlementUnitBalance struct {
+	SettlementUnit
+	Share  Money                           `json:"share"`
+	Shares ParticipantShareByParticipantId `json:"shares"`
+}
+
+type UnitsSettlement struct {
+	Units     []SettlementUnitBalance `json:"units"`     // sorted by representative id
+	Transfers []SettlementTransfer    `json:"transfers"` // between the units' representatives
+}
+
+// Aggregates the shares per settlement unit, participants outside every unit form a unit on their own, and builds the transfers
+// that settle the units (see BuildSettlementTransfers)
+func BuildUnitsSettlement(shares ParticipantShareByParticipantId, units []SettlementUnit) (UnitsSettlement, error) {
+	err := EnsureSettlementUnitsAreValid(units)
+	if err != nil {
+		return UnitsSettlement{}, err
+	}
+	currency, err := shares.getCurrency()
+	if err != nil {
+		return UnitsSettlement{}, err
+	}
+	unitByRepresentativeId := make(map[int]SettlementUnit)
+	representativeIdByParticipantId := make(map[int]int)
+	for _, unit := range units {
+		unitByRepresentativeId[unit.GetRepresentativeId()] = unit
+		for _, participantId := range unit.ParticipantIds {
+			representativeIdByParticipantId[participantId] = unit.GetRepresentativeId()
+		}
+	}
+	for _, participantId := range getSortedParticipantIds(shares) {
+		if _, exists := representativeIdByParticipantId[participantId]; !exists {
+			unitByRepresentativeId[participantId] = SettlementUnit{ParticipantIds: []int{participantId}}
+			representativeIdByParticipantId[participantId] = participantId
+		}
+	}
+
+	balanceByRepresentativeId := make(map[int]*SettlementUnitBalance)
+	for representativeId, unit := range unitByRepresentativeId {
+		balanceByRepresentativeId[representativeId] = &SettlementUnitBalance{SettlementUnit: unit, Share: NewMoney(0, currency), Shares: make(ParticipantShareByParticipantId)}
+	}
+	unitShares := make(map[int]Price)
+	for participantId, share := range shares {
+		representativeId := representativeIdByParticipantId[participantId]
+		balanceByRepresentativeId[representativeId].Share.Amount += share.Amount
+		balanceByRepresentativeId[representativeId].Shares[participantId] = share
+		unitShares[representativeId] += share.Amount
+	}
+
+	balances := make([]SettlementUnitBalance, 0, len(balanceByRepresentativeId))
+	for _, balance := range balanceByRepresentativeId {
+		balances = append(balances, *balance)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].GetRepresentativeId() < balances[j].GetRepresentativeId()
+	})
+	transfers, err := BuildSettlementTransfers(newParticipantShares(unitShares, currency))
+	if err != nil {
+		return UnitsSettlement{}, err
+	}
+	return UnitsSettlement{Units: balances, Transfers: transfers}, nil
+}
+
+// The transfers that leave every participant even, not only the units: each member evens up with its unit's representative
+// (who pays and receives on behalf of the unit) before the representatives settle the units among them
+func (settlement UnitsSettlement) BuildParticipantsTransfers() []SettlementTransfer {
+	transfers := []SettlementTransfer{}
+	for _, unit := range settlement.Units {
+		representativeId := unit.GetRepresentativeId()
+		for _, participantId := range unit.ParticipantIds[1:] {
+			share := unit.Shares[participantId]
+			if share.Amount < 0 {
+				transfers = append(transfers, SettlementTransfer{FromParticipantId: participantId, ToParticipantId: representativeId, Amount: share.Negate()})
+			} else if share.Amount > 0 {
+				transfers = append(transfers, SettlementTransfer{FromParticipantId: representativeId, ToParticipantId: participantId, Amount: share})
+			}
+		}
+	}
+	return append(transfers, settlement.Transfers...)
+}
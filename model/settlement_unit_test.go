@@ -0,0 +1,50 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildUnitsSettlement(t *testing.T) {
+	shares := newParticipantShares(shareAmounts{1: -300, 2: 500, 3: -400, 4: 200}, "ARS")
+	units := []SettlementUnit{{Name: "Ana y Bruno", ParticipantIds: []int{2, 1}}}
+
+	settlement, err := BuildUnitsSettlement(shares, units)
+	if err != nil {
+		t.Fatalf("Failed to build units settlement: %v", err)
+	}
+	expected := UnitsSettlement{
+		Units: []SettlementUnitBalance{
+			{SettlementUnit: SettlementUnit{Name: "Ana y Bruno", ParticipantIds: []int{2, 1}}, Share: ars(200), Shares: newParticipantShares(shareAmounts{1: -300, 2: 500}, "ARS")},
+			{SettlementUnit: SettlementUnit{ParticipantIds: []int{3}}, Share: ars(-400), Shares: newParticipantShares(shareAmounts{3: -400}, "ARS")},
+			{SettlementUnit: SettlementUnit{ParticipantIds: []int{4}}, Share: ars(200), Shares: newParticipantShares(shareAmounts{4: 200}, "ARS")},
+		},
+		Transfers: []SettlementTransfer{
+			{FromParticipantId: 3, ToParticipantId: 2, Amount: ars(200)},
+			{FromParticipantId: 3, ToParticipantId: 4, Amount: ars(200)},
+		},
+	}
+	if !reflect.DeepEqual(settlement, expected) {
+		t.Errorf("got %+v, expected %+v", settlement, expected)
+	}
+	expectedParticipantsTransfers := []SettlementTransfer{
+		{FromParticipantId: 1, ToParticipantId: 2, Amount: ars(300)}, // Ana evens up with Bruno, who settles for both
+		{FromParticipantId: 3, ToParticipantId: 2, Amount: ars(200)},
+		{FromParticipantId: 3, ToParticipantId: 4, Amount: ars(200)},
+	}
+	participantsTransfers := settlement.BuildParticipantsTransfers()
+	if !reflect.DeepEqual(participantsTransfers, expectedParticipantsTransfers) {
+		t.Errorf("got %+v, expected %+v", participantsTransfers, expectedParticipantsTransfers)
+	}
+
+	invalidUnits := [][]SettlementUnit{
+		{{Name: "Vacía"}},
+		{{ParticipantIds: []int{1, 2}}, {ParticipantIds: []int{2, 3}}},
+	}
+	for _, units := range invalidUnits {
+		_, err = BuildUnitsSettlement(shares, units)
+		if err != ErrInvalidSettlementUnits {
+			t.Errorf("got error %v, expected %v", err, ErrInvalidSettlementUnits)
+		}
+	}
+}
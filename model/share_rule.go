@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+)
+
+// a cap limits how much a participant consumes of a movement while a sponsor takes over the whole consumption of another participant
+type ShareRuleKind string
+
+const (
+	CapRule     ShareRuleKind = "cap"
+	SponsorRule ShareRuleKind = "sponsor"
+)
+
+// A rule on a participant's part of a movement, e.g. "Dani covers up to 5000 of the dinner" or "the company covers Carla's share"
+type ShareRule struct {
+	Kind          ShareRuleKind `json:"kind"`
+	ParticipantId int           `json:"participantId"`
+	Cap           Price         `json:"cap,omitempty"`       // only meaningful for caps, the most the participant consumes
+	SponsorId     int           `json:"sponsorId,omitempty"` // only meaningful for sponsors, who takes over the participant's consumption
+}
+
+var ErrUnknownShareRuleKind error = errors.New("The share rule kind is not supported")
+var ErrInvalidShareRule error = errors.New("Share rules must refer to participants of the movement, at most one per participant, caps must not be negative and a sponsor must not be sponsored nor sponsor itself")
+var ErrShareRulesCanNotBeSatisfied error = errors.New("Nobody is left to take the excess of the capped participants")
+
+func EnsureShareRulesAreValid(movement Movement, participantMovements []ParticipantMovement) error {
+	isParticipantById := make(map[int]bool)
+	for _, participantMovement := range participantMovements {
+		isParticipantById[participantMovement.ParticipantId] = true
+	}
+	hasRuleByParticipantId := make(map[int]bool)
+	isSponsoredByParticipantId := make(map[int]bool)
+	for _, rule := range movement.ShareRules {
+		if !isParticipantById[rule.ParticipantId] || hasRuleByParticipantId[rule.ParticipantId] {
+			return ErrInvalidShareRule
+		}
+		hasRuleByParticipantId[rule.ParticipantId] = true
+		switch rule.Kind {
+		case CapRule:
+			if rule.Cap < 0 {
+				return ErrInvalidShareRule
+			}
+		case SponsorRule:
+			if !isParticipantById[rule.SponsorId] || rule.SponsorId == rule.ParticipantId {
+				return ErrInvalidShareRule
+			}
+			isSponsoredByParticipantId[rule.ParticipantId] = true
+		default:
+			return ErrUnknownShareRuleKind
+		}
+	}
+	for _, rule := range movement.ShareRules {
+		if rule.Kind == SponsorRule && isSponsoredByParticipantId[rule.SponsorId] {
+			return ErrInvalidShareRule
+		}
+	}
+	return nil
+}
+
+// Applies the movement's share rules over the shares built by the split: first the sponsors take over the consumption of the
+// participants they sponsor and then the consumption exceeding each cap is redistributed among the uncapped participants in
+// proportion to what they consumed, until nobody exceeds their cap. The units lost in that division go by largest remainder
+// whatever the group's policy, as the largest payer may be capped and must not take any of the excess back.
+func applyShareRules(movement Movement, participantMovements []ParticipantMovement, shares ParticipantShareByParticipantId) (ParticipantShareByParticipantId, error) {
+	if len(movement.ShareRules) == 0 {
+		return shares, nil
+	}
+	err := EnsureShareRulesAreValid(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+
+	paidByParticipantId := make(map[int]Price)
+	for _, participantMovement := range participantMovements {
+		paidByParticipantId[participantMovement.ParticipantId] += participantMovement.Amount
+	}
+	consumedByParticipantId := make(map[int]Price)
+	for participantId, share := range shares {
+		consumedByParticipantId[participantId] = paidByParticipantId[participantId] - share.Amount
+	}
+
+	for _, rule := range movement.ShareRules {
+		if rule.Kind == SponsorRule {
+			consumedByParticipantId[rule.SponsorId] += consumedByParticipantId[rule.ParticipantId]
+			consumedByParticipantId[rule.ParticipantId] = 0
+		}
+	}
+
+	sortRemainderRecipients, err := LargestRemainderPolicy.remainderRecipientsSorter(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+	isCappedByParticipantId := make(map[int]bool)
+	for iteration := 0; ; iteration++ {
+		if iteration > len(movement.ShareRules) { // each round caps somebody new, so it must have settled by now
+			return nil, ErrShareRulesCanNotBeSatisfied
+		}
+		excess := 0
+		for _, rule := range movement.ShareRules {
+			if rule.Kind == CapRule && consumedByParticipantId[rule.ParticipantId] > rule.Cap {
+				excess += consumedByParticipantId[rule.ParticipantId] - rule.Cap
+				consumedByParticipantId[rule.ParticipantId] = rule.Cap
+				isCappedByParticipantId[rule.ParticipantId] = true
+			}
+		}
+		if excess == 0 {
+			break
+		}
+		weightByParticipantId := make(map[int]int)
+		for participantId, consumed := range consumedByParticipantId {
+			if !isCappedByParticipantId[participantId] && consumed > 0 {
+				weightByParticipantId[participantId] = consumed
+			}
+		}
+		if len(weightByParticipantId) == 0 {
+			return nil, ErrShareRulesCanNotBeSatisfied
+		}
+		excessByParticipantId, err := distributeProportionally(excess, weightByParticipantId, sortRemainderRecipients)
+		if err != nil {
+			return nil, err
+		}
+		for participantId, part := range excessByParticipantId {
+			consumedByParticipantId[participantId] += part
+		}
+	}
+
+	ruledShares := make(ParticipantShareByParticipantId)
+	for participantId, consumed := range consumedByParticipantId {
+		ruledShares[participantId] = NewMoney(paidByParticipantId[participantId]-consumed, movement.Currency)
+	}
+	return ruledShares, nil
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyShareRules(t *testing.T) {
+	participantMovements := []ParticipantMovement{
+		{ParticipantId: 1, Amount: 0},
+		{ParticipantId: 2, Amount: 0},
+		{ParticipantId: 3, Amount: 900},
+	}
+	sponsoredParticipantMovements := append(participantMovements, ParticipantMovement{ParticipantId: 4, Role: SponsorRole})
+
+	tests := []struct {
+		name                 string
+		rules                []ShareRule
+		participantMovements []ParticipantMovement
+		expected             shareAmounts
+	}{
+		{
+			name:                 "Sin reglas",
+			participantMovements: participantMovements,
+			expected:             shareAmounts{1: -300, 2: -300, 3: 600},
+		},
+		{
+			name:                 "El excedente del tope se reparte entre el resto",
+			rules:                []ShareRule{{Kind: CapRule, ParticipantId: 1, Cap: 100}},
+			participantMovements: participantMovements,
+			expected:             shareAmounts{1: -100, 2: -400, 3: 500},
+		},
+		{
+			name:                 "Los topes se aplican en cascada",
+			rules:                []ShareRule{{Kind: CapRule, ParticipantId: 1, Cap: 100}, {Kind: CapRule, ParticipantId: 2, Cap: 350}},
+			participantMovements: participantMovements,
+			expected:             shareAmounts{1: -100, 2: -350, 3: 450},
+		},
+		{
+			name:                 "El padrino se hace cargo de la parte del apadrinado",
+			rules:                []ShareRule{{Kind: SponsorRule, ParticipantId: 2, SponsorId: 4}},
+			participantMovements: sponsoredParticipantMovements,
+			expected:             shareAmounts{1: -300, 2: 0, 3: 600, 4: -300},
+		},
+		{
+			name:                 "El padrino con tope",
+			rules:                []ShareRule{{Kind: SponsorRule, ParticipantId: 2, SponsorId: 4}, {Kind: CapRule, ParticipantId: 4, Cap: 200}},
+			participantMovements: sponsoredParticipantMovements,
+			expected:             shareAmounts{1: -350, 2: 0, 3: 550, 4: -200},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			movement := Movement{Amount: 900, ShareRules: test.rules}
+			shares, err := BuildParticipantsShare(movement, test.participantMovements, LargestRemainderPolicy)
+			if err != nil {
+				t.Fatalf("Failed to build shares: %v", err)
+			}
+			if !reflect.DeepEqual(shares, test.expected.toShares()) {
+				t.Errorf("got %v, expected %v", shares, test.expected)
+			}
+			err = EnsureSharesSumToZero(shares)
+			if err != nil {
+				t.Errorf("Shares must sum to zero: %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyShareRulesWithCappedLargestPayer(t *testing.T) {
+	participantMovements := []ParticipantMovement{
+		{ParticipantId: 1, Amount: 0},
+		{ParticipantId: 2, Amount: 0},
+		{ParticipantId: 3, Amount: 900},
+	}
+	movement := Movement{Amount: 900, ShareRules: []ShareRule{{Kind: CapRule, ParticipantId: 3, Cap: 101}}}
+	shares, err := BuildParticipantsShare(movement, participantMovements, LargestPayerPolicy)
+	if err != nil {
+		t.Fatalf("Failed to build shares: %v", err)
+	}
+	expected := shareAmounts{1: -400, 2: -399, 3: 799}
+	if !reflect.DeepEqual(shares, expected.toShares()) {
+		t.Errorf("got %v, expected %v", shares, expected)
+	}
+}
+
+func TestInvalidShareRules(t *testing.T) {
+	participantMovements := []ParticipantMovement{
+		{ParticipantId: 1, Amount: 0},
+		{ParticipantId: 2, Amount: 0},
+		{ParticipantId: 3, Amount: 900},
+	}
+
+	tests := []struct {
+		name     string
+		rules    []ShareRule
+		expected error
+	}{
+		{"Tipo desconocido", []ShareRule{{Kind: "discount", ParticipantId: 1}}, ErrUnknownShareRuleKind},
+		{"Participante ajeno al movimiento", []ShareRule{{Kind: CapRule, ParticipantId: 7, Cap: 100}}, ErrInvalidShareRule},
+		{"Tope negativo", []ShareRule{{Kind: CapRule, ParticipantId: 1, Cap: -1}}, ErrInvalidShareRule},
+		{"Dos reglas para el mismo participante", []ShareRule{{Kind: CapRule, ParticipantId: 1, Cap: 100}, {Kind: SponsorRule, ParticipantId: 1, SponsorId: 2}}, ErrInvalidShareRule},
+		{"Padrino ajeno al movimiento", []ShareRule{{Kind: SponsorRule, ParticipantId: 1, SponsorId: 7}}, ErrInvalidShareRule},
+		{"Padrino de sí mismo", []ShareRule{{Kind: SponsorRule, ParticipantId: 1, SponsorId: 1}}, ErrInvalidShareRule},
+		{"Padrino apadrinado", []ShareRule{{Kind: SponsorRule, ParticipantId: 1, SponsorId: 2}, {Kind: SponsorRule, ParticipantId: 2, SponsorId: 3}}, ErrInvalidShareRule},
+		{"Todos con tope", []ShareRule{{Kind: CapRule, ParticipantId: 1, Cap: 100}, {Kind: CapRule, ParticipantId: 2, Cap: 100}, {Kind: CapRule, ParticipantId: 3, Cap: 100}}, ErrShareRulesCanNotBeSatisfied},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			movement := Movement{Amount: 900, ShareRules: test.rules}
+			_, err := BuildParticipantsShare(movement, participantMovements, LargestRemainderPolicy)
+			if err != test.expected {
+				t.Errorf("got error %v, expected %v", err, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"errors"
+	"sort"
+)
+
+type SplitStrategy string
+
+const (
+	EqualSplit      SplitStrategy = "equal"
+	WeightedSplit   SplitStrategy = "weights"
+	PercentageSplit SplitStrategy = "percentage"
+	ExactSplit      SplitStrategy = "exact"
+	ItemizedSplit   SplitStrategy = "items"    // the consumed amounts are derived from the movement's items
+	PresenceSplit   SplitStrategy = "presence" // a weighted split whose weights are the nights each participant was present (see BuildParticipantsPresenceWeights)
+)
+
+var ErrUnknownSplitStrategy error = errors.New("The split strategy is not supported")
+var ErrInvalidWeights error = errors.New("Weights must not be negative and at least one of them must be positive")
+var ErrPercentagesDoNotSumToHundred error = errors.New("The sum of percentages must equal one hundred")
+var ErrInvalidConsumedAmount error = errors.New("Consumed amounts must not be negative")
+
+// Builds the participants' shares according to the movement's split strategy (a movement without strategy is split in equal parts),
+// the units that can not be evenly split are allocated following the given remainder policy. The movement's surcharges, if any,
+// are allocated in proportion to what each participant consumed and its share rules (caps and sponsors) are applied last.
+func BuildParticipantsShare(movement Movement, participantMovements []ParticipantMovement, policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	err := EnsureParticipantRolesAreConsistent(participantMovements)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := buildParticipantsShareWithSurcharges(movement, participantMovements, func(subtotalMovement Movement) (ParticipantShareByParticipantId, error) {
+		return buildParticipantsShareBySplitStrategy(subtotalMovement, participantMovements, policy)
+	}, policy)
+	if err != nil {
+		return nil, err
+	}
+	return applyShareRules(movement, participantMovements, shares)
+}
+
+func buildParticipantsShareBySplitStrategy(movement Movement, participantMovements []ParticipantMovement, policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	switch movement.SplitStrategy {
+	case "", EqualSplit:
+		return buildParticipantsEqualShare(movement, participantMovements, policy)
+	case WeightedSplit, PresenceSplit:
+		return BuildParticipantsWeightedShare(movement, participantMovements, policy)
+	case PercentageSplit:
+		return BuildParticipantsPercentageShare(movement, participantMovements, policy)
+	case ExactSplit:
+		return BuildParticipantsExactShare(movement, participantMovements)
+	case ItemizedSplit:
+		return BuildParticipantsItemizedShare(movement, participantMovements, policy)
+	default:
+		return nil, ErrUnknownSplitStrategy
+	}
+}
+
+// every beneficiary takes one part, or as many parts as their weight when given (see Participant.Weight)
+func buildParticipantsEqualShare(movement Movement, participantMovements []ParticipantMovement, policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	if len(participantMovements) == 0 {
+		return make(ParticipantShareByParticipantId), nil
+	}
+	weightByParticipantId := make(map[int]int)
+	for _, participantMovement := range participantMovements {
+		if participantMovement.Weight < 0 {
+			return nil, ErrInvalidWeights
+		}
+		if !participantMovement.IsBeneficiary() {
+			weightByParticipantId[participantMovement.ParticipantId] = 0
+		} else if participantMovement.Weight == 0 {
+			weightByParticipantId[participantMovement.ParticipantId] = 1
+		} else {
+			weightByParticipantId[participantMovement.ParticipantId] = participantMovement.Weight // a participant standing for many (e.g. a couple)
+		}
+	}
+	return buildParticipantsProportionalShare(movement, participantMovements, weightByParticipantId, policy)
+}
+
+func BuildParticipantsWeightedShare(movement Movement, participantMovements []ParticipantMovement, policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	weightByParticipantId := make(map[int]int)
+	for _, participantMovement := range participantMovements {
+		if participantMovement.Weight < 0 {
+			return nil, ErrInvalidWeights
+		}
+		weightByParticipantId[participantMovement.ParticipantId] = participantMovement.Weight
+	}
+	return buildParticipantsProportionalShare(movement, participantMovements, weightByParticipantId, policy)
+}
+
+// a percentage split is a weighted split whose weights (the percentages) add up to 100
+func BuildParticipantsPercentageShare(movement Movement, participantMovements []ParticipantMovement, policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	totalPercentage := 0
+	for _, participantMovement := range participantMovements {
+		totalPercentage += participantMovement.Weight
+	}
+	if totalPercentage != 100 {
+		return nil, ErrPercentagesDoNotSumToHundred
+	}
+	return BuildParticipantsWeightedShare(movement, participantMovements, policy)
+}
+
+func buildParticipantsProportionalShare(movement Movement, participantMovements []ParticipantMovement, weightByParticipantId map[int]int, policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	sortRemainderRecipients, err := policy.remainderRecipientsSorter(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+	consumedByParticipantId, err := distributeProportionally(movement.Amount, weightByParticipantId, sortRemainderRecipients)
+	if err != nil {
+		return nil, err
+	}
+	return buildSharesFromConsumedAmounts(movement, participantMovements, consumedByParticipantId), nil
+}
+
+// each participant states exactly how much they consumed, so the share is what they paid minus what they consumed
+func BuildParticipantsExactShare(movement Movement, participantMovements []ParticipantMovement) (ParticipantShareByParticipantId, error) {
+	consumedByParticipantId := make(map[int]Price)
+	for _, participantMovement := range participantMovements {
+		if participantMovement.Consumed < 0 {
+			return nil, ErrInvalidConsumedAmount
+		}
+		consumedByParticipantId[participantMovement.ParticipantId] = participantMovement.Consumed
+	}
+	err := EnsureMovementAmountMatchesParticipantConsumedAmounts(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+	return buildSharesFromConsumedAmounts(movement, participantMovements, consumedByParticipantId), nil
+}
+
+// share = paid - consumed in the movement's currency, a positive share means the participant is owed money and a negative one that the participant owes money
+func buildSharesFromConsumedAmounts(movement Movement, participantMovements []ParticipantMovement, consumedByParticipantId map[int]Price) ParticipantShareByParticipantId {
+	participantShareByParticipantId := make(ParticipantShareByParticipantId)
+	for _, participantMovement := range participantMovements {
+		participantShareByParticipantId[participantMovement.ParticipantId] = NewMoney(participantMovement.Amount-consumedByParticipantId[participantMovement.ParticipantId], movement.Currency)
+	}
+	return participantShareByParticipantId
+}
+
+// Distributes the amount among the participants in proportion to their weights, the units lost by the integer division
+// are given one by one to the participants in the order decided by the remainder policy, so the parts always add up to the amount.
+func distributeProportionally(amount Price, weightByParticipantId map[int]int, sortRemainderRecipients remainderRecipientsSorter) (map[int]Price, error) {
+	totalWeight := 0
+	for _, weight := range weightByParticipantId {
+		if weight < 0 {
+			return nil, ErrInvalidWeights
+		}
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return nil, ErrInvalidWeights
+	}
+
+	candidateIds := make([]int, 0, len(weightByParticipantId)) // only those with weight take part of the remainder
+	for id, weight := range weightByParticipantId {
+		if weight > 0 {
+			candidateIds = append(candidateIds, id)
+		}
+	}
+	sort.Ints(candidateIds)
+
+	partByParticipantId := make(map[int]Price)
+	remainderByParticipantId := make(map[int]int)
+	distributed := 0
+	for id, weight := range weightByParticipantId {
+		exactPart := amount * weight
+		partByParticipantId[id] = exactPart / totalWeight
+		remainderByParticipantId[id] = exactPart % totalWeight
+		distributed += partByParticipantId[id]
+	}
+
+	recipientIds := sortRemainderRecipients(candidateIds, remainderByParticipantId)
+	for i := 0; i < amount-distributed; i++ {
+		partByParticipantId[recipientIds[i%len(recipientIds)]]++
+	}
+	return partByParticipantId, nil
+}
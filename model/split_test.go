@@ -0,0 +1,220 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildParticipantsShareBySplitStrategy(t *testing.T) {
+	tests := []struct {
+		name                 string
+		movement             Movement
+		participantMovements []ParticipantMovement
+		expectedShares       shareAmounts
+		expectedErr          error
+	}{
+		{
+			name:     "Movement without strategy is split in equal parts",
+			movement: Movement{Id: 1, Amount: 1000},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 1000},
+				{ParticipantId: 2, MovementId: 1, Amount: 0},
+			},
+			expectedShares: shareAmounts{1: 500, 2: -500},
+		},
+		{
+			name:     "Equal split honours the weight of a participant standing for a couple",
+			movement: Movement{Id: 1, Amount: 900, SplitStrategy: EqualSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 900, Weight: 2},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Weight: 1},
+				{ParticipantId: 3, MovementId: 1, Amount: 0},
+			},
+			expectedShares: shareAmounts{1: 450, 2: -225, 3: -225},
+		},
+		{
+			name:     "Equal split with a negative weight is rejected",
+			movement: Movement{Id: 1, Amount: 900, SplitStrategy: EqualSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 900, Weight: -2},
+				{ParticipantId: 2, MovementId: 1, Amount: 0},
+			},
+			expectedErr: ErrInvalidWeights,
+		},
+		{
+			name:     "Rent split by room size (weights 2, 1 and 1)",
+			movement: Movement{Id: 1, Amount: 1000, SplitStrategy: WeightedSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 1000, Weight: 2},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Weight: 1},
+				{ParticipantId: 3, MovementId: 1, Amount: 0, Weight: 1},
+			},
+			expectedShares: shareAmounts{1: 500, 2: -250, 3: -250},
+		},
+		{
+			name:     "Trip split by nights stayed, participant with no nights pays nothing",
+			movement: Movement{Id: 1, Amount: 900, SplitStrategy: WeightedSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 0, Weight: 0},
+				{ParticipantId: 2, MovementId: 1, Amount: 900, Weight: 1},
+				{ParticipantId: 3, MovementId: 1, Amount: 0, Weight: 2},
+			},
+			expectedShares: shareAmounts{1: 0, 2: 600, 3: -600},
+		},
+		{
+			name:     "Weighted split not evenly divisible gives the lost units to the largest remainders",
+			movement: Movement{Id: 1, Amount: 100, SplitStrategy: WeightedSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 100, Weight: 1},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Weight: 1},
+				{ParticipantId: 3, MovementId: 1, Amount: 0, Weight: 1},
+			},
+			expectedShares: shareAmounts{1: 66, 2: -33, 3: -33},
+		},
+		{
+			name:     "Percentage split",
+			movement: Movement{Id: 1, Amount: 2000, SplitStrategy: PercentageSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000, Weight: 10},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Weight: 90},
+			},
+			expectedShares: shareAmounts{1: 1800, 2: -1800},
+		},
+		{
+			name:     "Percentages not adding up to one hundred are rejected",
+			movement: Movement{Id: 1, Amount: 2000, SplitStrategy: PercentageSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000, Weight: 10},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Weight: 80},
+			},
+			expectedErr: ErrPercentagesDoNotSumToHundred,
+		},
+		{
+			name:     "Weights all zero are rejected",
+			movement: Movement{Id: 1, Amount: 2000, SplitStrategy: WeightedSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000},
+				{ParticipantId: 2, MovementId: 1, Amount: 0},
+			},
+			expectedErr: ErrInvalidWeights,
+		},
+		{
+			name:     "Negative weights are rejected",
+			movement: Movement{Id: 1, Amount: 2000, SplitStrategy: WeightedSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000, Weight: 3},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Weight: -1},
+			},
+			expectedErr: ErrInvalidWeights,
+		},
+		{
+			name:     "Itemised restaurant bill, each participant states what they consumed",
+			movement: Movement{Id: 1, Amount: 3000, SplitStrategy: ExactSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000, Consumed: 500},
+				{ParticipantId: 2, MovementId: 1, Amount: 1000, Consumed: 1200},
+				{ParticipantId: 3, MovementId: 1, Amount: 0, Consumed: 1300},
+			},
+			expectedShares: shareAmounts{1: 1500, 2: -200, 3: -1300},
+		},
+		{
+			name:     "Consumed amounts not adding up to the movement amount are rejected",
+			movement: Movement{Id: 1, Amount: 3000, SplitStrategy: ExactSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000, Consumed: 500},
+				{ParticipantId: 2, MovementId: 1, Amount: 1000, Consumed: 1200},
+			},
+			expectedErr: ErrMovementConsumedAmountMismatch,
+		},
+		{
+			name:     "Negative consumed amounts are rejected",
+			movement: Movement{Id: 1, Amount: 3000, SplitStrategy: ExactSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 3000, Consumed: 3500},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Consumed: -500},
+			},
+			expectedErr: ErrInvalidConsumedAmount,
+		},
+		{
+			name:     "Gift paid by participant 1 and shared only by participants 3 and 4",
+			movement: Movement{Id: 1, Amount: 3000},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 3000, Role: PayerRole},
+				{ParticipantId: 3, MovementId: 1, Amount: 0, Role: BeneficiaryRole},
+				{ParticipantId: 4, MovementId: 1, Amount: 0, Role: BeneficiaryRole},
+			},
+			expectedShares: shareAmounts{1: 3000, 3: -1500, 4: -1500},
+		},
+		{
+			name:     "Payer that also shares the cost along with a beneficiary, weighted",
+			movement: Movement{Id: 1, Amount: 3000, SplitStrategy: WeightedSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 1000, Role: PayerRole},
+				{ParticipantId: 2, MovementId: 1, Amount: 2000, Weight: 1, Role: PayerAndBeneficiaryRole},
+				{ParticipantId: 3, MovementId: 1, Amount: 0, Weight: 2, Role: BeneficiaryRole},
+			},
+			expectedShares: shareAmounts{1: 1000, 2: 1000, 3: -2000},
+		},
+		{
+			name:     "Beneficiary only paying is rejected",
+			movement: Movement{Id: 1, Amount: 3000},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000, Role: PayerRole},
+				{ParticipantId: 2, MovementId: 1, Amount: 1000, Role: BeneficiaryRole},
+			},
+			expectedErr: ErrBeneficiaryOnlyCanNotPay,
+		},
+		{
+			name:     "Payer only consuming is rejected",
+			movement: Movement{Id: 1, Amount: 3000, SplitStrategy: ExactSplit},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 3000, Consumed: 1000, Role: PayerRole},
+				{ParticipantId: 2, MovementId: 1, Amount: 0, Consumed: 2000, Role: BeneficiaryRole},
+			},
+			expectedErr: ErrPayerOnlyCanNotConsume,
+		},
+		{
+			name:     "Movement without beneficiaries is rejected",
+			movement: Movement{Id: 1, Amount: 3000},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 3000, Role: PayerRole},
+			},
+			expectedErr: ErrMovementWithoutBeneficiaries,
+		},
+		{
+			name:     "Unknown role is rejected",
+			movement: Movement{Id: 1, Amount: 3000},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 3000, Role: "guest"},
+			},
+			expectedErr: ErrUnknownParticipantRole,
+		},
+		{
+			name:     "Unknown strategy is rejected",
+			movement: Movement{Id: 1, Amount: 2000, SplitStrategy: "whatever"},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, MovementId: 1, Amount: 2000},
+			},
+			expectedErr: ErrUnknownSplitStrategy,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, err := BuildParticipantsShare(test.movement, test.participantMovements, DefaultRemainderPolicy)
+			if err != test.expectedErr {
+				t.Fatalf("generated error %v, expected error %v", err, test.expectedErr)
+			}
+			if test.expectedErr != nil {
+				return
+			}
+			err = EnsureSharesSumToZero(shares)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if !reflect.DeepEqual(shares, test.expectedShares.toShares()) {
+				t.Errorf("generated share %v, expected share %v", shares, test.expectedShares)
+			}
+		})
+	}
+}
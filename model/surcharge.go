@@ -0,0 +1,111 @@
+package model
+
+import (
+	"errors"
+	"math"
+)
+
+type SurchargeKind string
+
+const (
+	TipSurcharge      SurchargeKind = "tip"
+	ServiceSurcharge  SurchargeKind = "service"
+	DeliverySurcharge SurchargeKind = "delivery"
+	BankFeeSurcharge  SurchargeKind = "bankFee"
+)
+
+// An extra charged on top of what the participants consumed (e.g. the tip), it is allocated in proportion to each participant's consumed amount
+type Surcharge struct {
+	Kind       SurchargeKind `json:"kind"`
+	Percentage float64       `json:"percentage,omitempty"` // when given, the amount is this percentage of the movement's subtotal
+	Amount     Price         `json:"amount"`               // included in the movement's amount
+}
+
+var ErrUnknownSurchargeKind error = errors.New("The surcharge kind is not supported")
+var ErrInvalidSurcharge error = errors.New("Surcharges must not be negative, must be either a percentage or a fixed amount and must not exceed the movement amount")
+
+// the movement's amount without surcharges, what the participants actually consumed
+func (movement Movement) GetSubtotal() Price {
+	subtotal := movement.Amount
+	for _, surcharge := range movement.Surcharges {
+		subtotal -= surcharge.Amount
+	}
+	return subtotal
+}
+
+func EnsureSurchargesAreValid(movement Movement) error {
+	for _, surcharge := range movement.Surcharges {
+		switch surcharge.Kind {
+		case TipSurcharge, ServiceSurcharge, DeliverySurcharge, BankFeeSurcharge:
+		default:
+			return ErrUnknownSurchargeKind
+		}
+		if surcharge.Amount < 0 || surcharge.Percentage < 0 {
+			return ErrInvalidSurcharge
+		}
+	}
+	if movement.GetSubtotal() < 0 {
+		return ErrInvalidSurcharge
+	}
+	return nil
+}
+
+// Works out the amount of the percentage surcharges out of the movement's total amount (subtotal plus every surcharge),
+// e.g. a total of 1150 with a 10% tip and a fixed delivery of 50 has a subtotal of 1000 and a tip of 100
+func ResolveSurcharges(total Price, surcharges []Surcharge) ([]Surcharge, error) {
+	fixedAmount := 0
+	totalPercentage := 0.0
+	for _, surcharge := range surcharges {
+		if surcharge.Amount < 0 || surcharge.Percentage < 0 || (surcharge.Percentage > 0 && surcharge.Amount > 0) {
+			return nil, ErrInvalidSurcharge
+		}
+		fixedAmount += surcharge.Amount
+		totalPercentage += surcharge.Percentage
+	}
+	subtotal := float64(total-fixedAmount) / (1 + totalPercentage/100)
+	resolved := make([]Surcharge, 0, len(surcharges))
+	for _, surcharge := range surcharges {
+		if surcharge.Percentage > 0 {
+			surcharge.Amount = Price(math.Round(subtotal * surcharge.Percentage / 100))
+		}
+		resolved = append(resolved, surcharge)
+	}
+	return resolved, nil
+}
+
+// Splits the movement's subtotal with the given split and then spreads the surcharges over the participants in proportion to what
+// they consumed, so the shares still sum to zero
+func buildParticipantsShareWithSurcharges(movement Movement, participantMovements []ParticipantMovement, buildShare func(subtotalMovement Movement) (ParticipantShareByParticipantId, error), policy RemainderPolicy) (ParticipantShareByParticipantId, error) {
+	err := EnsureSurchargesAreValid(movement)
+	if err != nil {
+		return nil, err
+	}
+	subtotalMovement := movement
+	subtotalMovement.Amount = movement.GetSubtotal()
+	subtotalMovement.Surcharges = nil
+	shares, err := buildShare(subtotalMovement)
+	if err != nil || len(movement.Surcharges) == 0 {
+		return shares, err
+	}
+
+	consumedByParticipantId := make(map[int]int) // as the payers paid the whole amount, the subtotal shares add up to the surcharges
+	for _, participantMovement := range participantMovements {
+		consumedByParticipantId[participantMovement.ParticipantId] += participantMovement.Amount
+	}
+	for participantId, share := range shares {
+		consumedByParticipantId[participantId] -= share.Amount
+	}
+	sortRemainderRecipients, err := policy.remainderRecipientsSorter(movement, participantMovements)
+	if err != nil {
+		return nil, err
+	}
+	surchargesAmount := movement.Amount - subtotalMovement.Amount
+	surchargeByParticipantId, err := distributeProportionally(surchargesAmount, consumedByParticipantId, sortRemainderRecipients)
+	if err != nil {
+		return nil, ErrInvalidSurcharge
+	}
+	for participantId, surcharge := range surchargeByParticipantId {
+		shares[participantId] = NewMoney(shares[participantId].Amount-surcharge, movement.Currency)
+	}
+	return shares, nil
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveSurcharges(t *testing.T) {
+	tests := []struct {
+		name          string
+		total         Price
+		surcharges    []Surcharge
+		expected      []Surcharge
+		expectedError error
+	}{
+		{
+			name:       "Percentage and fixed surcharges",
+			total:      1150,
+			surcharges: []Surcharge{{Kind: TipSurcharge, Percentage: 10}, {Kind: DeliverySurcharge, Amount: 50}},
+			expected:   []Surcharge{{Kind: TipSurcharge, Percentage: 10, Amount: 100}, {Kind: DeliverySurcharge, Amount: 50}},
+		},
+		{
+			name:       "Percentage rounded to the nearest unit",
+			total:      1000,
+			surcharges: []Surcharge{{Kind: ServiceSurcharge, Percentage: 15}},
+			expected:   []Surcharge{{Kind: ServiceSurcharge, Percentage: 15, Amount: 130}},
+		},
+		{
+			name:          "Both percentage and amount",
+			total:         1000,
+			surcharges:    []Surcharge{{Kind: TipSurcharge, Percentage: 10, Amount: 100}},
+			expectedError: ErrInvalidSurcharge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := ResolveSurcharges(test.total, test.surcharges)
+			if err != test.expectedError {
+				t.Fatalf("got error %v, expected %v", err, test.expectedError)
+			}
+			if err == nil && !reflect.DeepEqual(resolved, test.expected) {
+				t.Errorf("got %v, expected %v", resolved, test.expected)
+			}
+		})
+	}
+}
+
+func TestSurchargesAreAllocatedInProportionToConsumedAmounts(t *testing.T) {
+	tests := []struct {
+		name                 string
+		movement             Movement
+		participantMovements []ParticipantMovement
+		expected             shareAmounts
+		expectedError        error
+	}{
+		{
+			name:     "Exact split with a tip",
+			movement: Movement{Id: 1, Amount: 1100, SplitStrategy: ExactSplit, Surcharges: []Surcharge{{Kind: TipSurcharge, Percentage: 10, Amount: 100}}},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, Amount: 1100, Consumed: 700},
+				{ParticipantId: 2, Amount: 0, Consumed: 300},
+			},
+			expected: shareAmounts{1: 330, 2: -330},
+		},
+		{
+			name:     "Uneven surcharges keep shares summing to zero",
+			movement: Movement{Id: 1, Amount: 1001, SplitStrategy: WeightedSplit, Surcharges: []Surcharge{{Kind: BankFeeSurcharge, Amount: 101}}},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, Amount: 1001, Weight: 1},
+				{ParticipantId: 2, Amount: 0, Weight: 1},
+				{ParticipantId: 3, Amount: 0, Weight: 1},
+			},
+			expected: shareAmounts{1: 667, 2: -334, 3: -333},
+		},
+		{
+			name:     "A payer that does not consume does not pay surcharges",
+			movement: Movement{Id: 1, Amount: 1200, Surcharges: []Surcharge{{Kind: DeliverySurcharge, Amount: 200}}},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, Amount: 1200, Role: PayerRole},
+				{ParticipantId: 2, Amount: 0},
+				{ParticipantId: 3, Amount: 0},
+			},
+			expected: shareAmounts{1: 1200, 2: -600, 3: -600},
+		},
+		{
+			name:     "Surcharges can not exceed the amount",
+			movement: Movement{Id: 1, Amount: 100, Surcharges: []Surcharge{{Kind: TipSurcharge, Amount: 200}}},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, Amount: 100},
+			},
+			expectedError: ErrInvalidSurcharge,
+		},
+		{
+			name:     "Unknown surcharge kind",
+			movement: Movement{Id: 1, Amount: 100, Surcharges: []Surcharge{{Kind: "cover", Amount: 10}}},
+			participantMovements: []ParticipantMovement{
+				{ParticipantId: 1, Amount: 100},
+			},
+			expectedError: ErrUnknownSurchargeKind,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, err := BuildParticipantsShare(test.movement, test.participantMovements, DefaultRemainderPolicy)
+			if err != test.expectedError {
+				t.Fatalf("got error %v, expected %v", err, test.expectedError)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(shares, test.expected.toShares()) {
+				t.Errorf("got %v, expected %v", shares, test.expected)
+			}
+			err = EnsureSharesSumToZero(shares)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
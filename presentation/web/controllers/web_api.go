@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/vituchon/splitify/model"
 	model_api "github.com/vituchon/splitify/model/api"
+	"github.com/vituchon/splitify/repositories"
 )
 
 func GetAllGroups(response http.ResponseWriter, request *http.Request) {
-	groups, err := model_api.GetAllGroups()
+	groups, err := model_api.GetAllGroups(request.Context())
 	if err != nil {
 		msg := fmt.Sprintf("error while retrieving groups : '%v'", err)
 		log.Println(msg)
@@ -28,11 +32,26 @@ func CreateGroup(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	createdGroup, err := model_api.CreateGroup(*name)
+	currency := model.DefaultCurrency
+	rawCurrency, err := ParseSingleStringUrlQueryParam(request, "currency")
+	if err == nil {
+		currency = model.Currency(*rawCurrency)
+	} else if err != UrlQueryParamNotFoundErr {
+		msg := fmt.Sprintf("error while creating group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	createdGroup, err := model_api.CreateGroupWithCurrency(request.Context(), *name, currency)
 	if err != nil {
 		msg := fmt.Sprintf("error while creating group : '%v'", err)
 		log.Println(msg)
-		http.Error(response, msg, http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if err == model.ErrInvalidCurrency {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
 		return
 	}
 	WriteJsonResponse(response, http.StatusOK, createdGroup)
@@ -46,7 +65,7 @@ func GetGroupParticipants(response http.ResponseWriter, request *http.Request) {
 		http.Error(response, msg, http.StatusBadRequest)
 		return
 	}
-	participants, err := model_api.GetParticipants(groupId)
+	participants, err := model_api.GetParticipants(request.Context(), groupId)
 	if err != nil {
 		msg := fmt.Sprintf("error while retrieving group participants: '%v'", err)
 		log.Println(msg)
@@ -76,13 +95,505 @@ func AddParcipantToGroup(response http.ResponseWriter, request *http.Request) {
 		GroupId: groupId,
 		Name:    *name,
 	}
+	weight, err := ParseSingleIntegerUrlQueryParam(request, "weight")
+	if err == nil {
+		participant.Weight = *weight
+	} else if err != UrlQueryParamNotFoundErr {
+		msg := fmt.Sprintf("error while adding participant to group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
 
-	createdParticipant, err := model_api.AddParticipant(participant)
+	createdParticipant, err := model_api.AddParticipant(request.Context(), participant)
 	if err != nil {
 		msg := fmt.Sprintf("error while adding participant to group : '%v'", err)
 		log.Println(msg)
-		http.Error(response, msg, http.StatusInternalServerError)
+		status := referenceErrStatus(err)
+		if err == model.ErrInvalidWeights || err == model.ErrInvalidPresenceInterval || err == model.ErrOverlappingPresenceIntervals {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
 		return
 	}
 	WriteJsonResponse(response, http.StatusOK, createdParticipant)
 }
+
+func UpdateParticipantWeight(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant weight : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	participantId, err := ParseRouteParamAsInt(request, "participantId")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant weight : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	weight, err := ParseSingleIntegerUrlQueryParam(request, "weight")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant weight : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	updatedParticipant, err := model_api.SetParticipantWeight(request.Context(), groupId, participantId, *weight)
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant weight : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if err == model.ErrInvalidWeights {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, updatedParticipant)
+}
+
+func UpdateParticipantPresences(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant presences : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	participantId, err := ParseRouteParamAsInt(request, "participantId")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant presences : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	var presences []model.PresenceInterval
+	err = parseJsonFromReader(request.Body, &presences)
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant presences : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	updatedParticipant, err := model_api.SetParticipantPresences(request.Context(), groupId, participantId, presences)
+	if err != nil {
+		msg := fmt.Sprintf("error while updating participant presences : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if err == model.ErrInvalidPresenceInterval || err == model.ErrOverlappingPresenceIntervals {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, updatedParticipant)
+}
+
+func UpdateGroupRemainderPolicy(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group remainder policy : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	policy, err := ParseSingleStringUrlQueryParam(request, "policy")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group remainder policy : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	updatedGroup, err := model_api.SetGroupRemainderPolicy(request.Context(), groupId, model.RemainderPolicy(*policy))
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group remainder policy : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if err == model.ErrUnknownRemainderPolicy {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, updatedGroup)
+}
+
+func UpdateGroupInflationAdjustment(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group inflation adjustment : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	rawEnabled, err := ParseSingleStringUrlQueryParam(request, "enabled")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group inflation adjustment : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	enabled, err := strconv.ParseBool(*rawEnabled)
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group inflation adjustment : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	updatedGroup, err := model_api.SetGroupInflationAdjustment(request.Context(), groupId, enabled)
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group inflation adjustment : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if err == model.ErrPriceIndexNotConfigured {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, updatedGroup)
+}
+
+// Nominal and inflation adjusted balances of the group, as of the unix timestamp given by the optional "asOf" query param or as of now
+func GetGroupBalances(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group balances : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	asOf := time.Now()
+	rawAsOf, err := ParseSingleIntegerUrlQueryParam(request, "asOf")
+	if err == nil {
+		asOf = time.Unix(int64(*rawAsOf), 0)
+	} else if err != UrlQueryParamNotFoundErr {
+		msg := fmt.Sprintf("error while retrieving group balances : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	balances, err := model_api.CalculateInflationAdjustedBalances(request.Context(), groupId, asOf)
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group balances : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if err == model.ErrPriceIndexNotConfigured { // the group was adjusted while the server had a price index that it lacks now
+			status = http.StatusConflict
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, balances)
+}
+
+// Debts of the group, as of the unix timestamp given by the optional "asOf" query param (only the installments due by then count) or in total
+func GetGroupDebts(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group debts : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	var asOf time.Time
+	rawAsOf, err := ParseSingleIntegerUrlQueryParam(request, "asOf")
+	if err == nil {
+		asOf = time.Unix(int64(*rawAsOf), 0)
+	} else if err != UrlQueryParamNotFoundErr {
+		msg := fmt.Sprintf("error while retrieving group debts : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	debitCreditMap, shares, err := model_api.CalculateBalancesAsOf(request.Context(), groupId, asOf)
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group debts : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, referenceErrStatus(err))
+		return
+	}
+	debts := struct {
+		DebitCredit model_api.DebitCreditMap       `json:"debitCredit"`
+		Shares      model_api.MoneyByParticipantId `json:"shares"`
+	}{
+		DebitCredit: debitCreditMap,
+		Shares:      shares,
+	}
+	WriteJsonResponse(response, http.StatusOK, debts)
+}
+
+func UpdateGroupSettlementUnits(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group settlement units : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	var units []model.SettlementUnit
+	err = parseJsonFromReader(request.Body, &units)
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group settlement units : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+
+	updatedGroup, err := model_api.SetGroupSettlementUnits(request.Context(), groupId, units)
+	if err != nil {
+		msg := fmt.Sprintf("error while updating group settlement units : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if err == model.ErrInvalidSettlementUnits {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, updatedGroup)
+}
+
+func GetGroupUnitsSettlement(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group units settlement : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	settlement, err := model_api.CalculateUnitsSettlement(request.Context(), groupId)
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group units settlement : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, referenceErrStatus(err))
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, settlement)
+}
+
+func GetGroupSettlement(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group settlement : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	transfers, err := model_api.CalculateSettlement(request.Context(), groupId)
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group settlement : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, referenceErrStatus(err))
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, transfers)
+}
+
+func SettleUpGroup(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while settling up group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	movements, err := model_api.SettleUp(request.Context(), groupId)
+	if err != nil {
+		msg := fmt.Sprintf("error while settling up group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, referenceErrStatus(err))
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, model_api.NewRecordedMovements(movements))
+}
+
+func SettleUpGroupPartially(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while settling up group partially : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	var transfer model.SettlementTransfer
+	err = parseJsonFromReader(request.Body, &transfer)
+	if err != nil {
+		msg := fmt.Sprintf("error while settling up group partially : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	movement, err := model_api.SettleUpPartially(request.Context(), groupId, transfer)
+	if err != nil {
+		msg := fmt.Sprintf("error while settling up group partially : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if err == model.ErrSettlementNotSuggested || err == model.ErrInvalidSettlementAmount || err == model.ErrCurrencyMismatch {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, model_api.NewRecordedMovement(*movement))
+}
+
+func GetGroupBalanceSheet(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group balance sheet : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	balanceSheet, err := model_api.CalculateBalanceSheet(request.Context(), groupId)
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group balance sheet : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, referenceErrStatus(err))
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, balanceSheet)
+}
+
+// errors caused by a movement that can not be entered as given, so they are client errors
+var movementValidationErrs = []error{
+	model.ErrNegativeMovementAmount,
+	model.ErrMovementAmountMismatch,
+	model.ErrMovementConsumedAmountMismatch,
+	model.ErrUnknownSplitStrategy,
+	model.ErrPercentagesDoNotSumToHundred,
+	model.ErrInvalidConsumedAmount,
+	model.ErrCurrencyMismatch,
+	model.ErrInvalidCurrency,
+	model.ErrExchangeRateNotFound,
+	model.ErrInvalidExchangeRate,
+	model.ErrInvalidInstallments,
+	model.ErrInvalidItem,
+	model.ErrUnknownItemKind,
+	model.ErrItemsAmountMismatch,
+	model.ErrInvalidSurcharge,
+	model.ErrUnknownSurchargeKind,
+	model.ErrInvalidMovementPeriod,
+	model.ErrNobodyPresent,
+	model.ErrInvalidWeights,
+	model.ErrUnknownShareRuleKind,
+	model.ErrInvalidShareRule,
+	model.ErrShareRulesCanNotBeSatisfied,
+	model.ErrUnknownParticipantRole,
+	model.ErrBeneficiaryOnlyCanNotPay,
+	model.ErrPayerOnlyCanNotConsume,
+	model.ErrMovementWithoutBeneficiaries,
+}
+
+func isMovementValidationErr(err error) bool {
+	for _, validationErr := range movementValidationErrs {
+		if err == validationErr {
+			return true
+		}
+	}
+	return false
+}
+
+// the status of an error caused by the entities a request refers to: a missing one is not found while a participant of another
+// group is a client error, any other error is taken as a server one
+func referenceErrStatus(err error) int {
+	switch err {
+	case repositories.EntityNotExistsErr:
+		return http.StatusNotFound
+	case model.ErrParticipantNotInGroup:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func GetGroupMovements(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group movements : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	movements, err := model_api.GetMovements(request.Context(), groupId)
+	if err != nil {
+		msg := fmt.Sprintf("error while retrieving group movements : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusInternalServerError)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, model_api.NewRecordedMovements(movements))
+}
+
+func AddMovementToGroup(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while adding movement to group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	var movement model_api.Movement
+	err = parseJsonFromReader(request.Body, &movement)
+	if err != nil {
+		msg := fmt.Sprintf("error while adding movement to group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	movement.GroupId = groupId
+
+	createdMovement, _, err := model_api.AddMovement(request.Context(), movement)
+	if err != nil {
+		msg := fmt.Sprintf("error while adding movement to group : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if isMovementValidationErr(err) {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, model_api.NewRecordedMovement(*createdMovement))
+}
+
+func AddTransferToGroup(response http.ResponseWriter, request *http.Request) {
+	groupId, err := ParseRouteParamAsInt(request, "groupId")
+	if err != nil {
+		msg := fmt.Sprintf("error while adding transfer to group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	var transfer model_api.Transfer
+	err = parseJsonFromReader(request.Body, &transfer)
+	if err != nil {
+		msg := fmt.Sprintf("error while adding transfer to group : '%v'", err)
+		log.Println(msg)
+		http.Error(response, msg, http.StatusBadRequest)
+		return
+	}
+	transfer.GroupId = groupId
+
+	createdMovement, _, err := model_api.AddTransfer(request.Context(), transfer)
+	if err != nil {
+		msg := fmt.Sprintf("error while adding transfer to group : '%v'", err)
+		log.Println(msg)
+		status := referenceErrStatus(err)
+		if isMovementValidationErr(err) || err == model.ErrInvalidTransfer {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, msg, status)
+		return
+	}
+	WriteJsonResponse(response, http.StatusOK, model_api.NewRecordedMovement(*createdMovement))
+}
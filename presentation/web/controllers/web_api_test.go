@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/vituchon/splitify/model"
+	model_api "github.com/vituchon/splitify/model/api"
+)
+
+func TestAddMovementToGroupRejectsInvalidMovements(t *testing.T) {
+	ctx := context.Background()
+	group, err := model_api.CreateGroup(ctx, "Cena")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	ana, _ := model_api.AddParticipant(ctx, model_api.Participant{GroupId: group.Id, Name: "Ana"})
+	bruno, _ := model_api.AddParticipant(ctx, model_api.Participant{GroupId: group.Id, Name: "Bruno"})
+
+	ars := func(amount model.Price) model.Money {
+		return model.NewMoney(amount, group.Currency)
+	}
+
+	tests := []struct {
+		name           string
+		splitStrategy  model.SplitStrategy
+		weights        []int
+		expectedStatus int
+	}{
+		{
+			name:           "Percentages summing one hundred",
+			splitStrategy:  model.PercentageSplit,
+			weights:        []int{60, 40},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Percentages not summing one hundred",
+			splitStrategy:  model.PercentageSplit,
+			weights:        []int{60, 30},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown split strategy",
+			splitStrategy:  "thirds",
+			weights:        []int{1, 1},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			movement := model_api.Movement{
+				Amount:        ars(1000),
+				Concept:       "Pizza",
+				SplitStrategy: test.splitStrategy,
+				ParticipantMovements: []model_api.ParticipantMovement{
+					{ParticipantId: ana.Id, Amount: ars(1000), Consumed: ars(0), Weight: test.weights[0]},
+					{ParticipantId: bruno.Id, Amount: ars(0), Consumed: ars(0), Weight: test.weights[1]},
+				},
+			}
+			body, err := json.Marshal(movement)
+			if err != nil {
+				t.Fatalf("Failed to marshal movement: %v", err)
+			}
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/groups/"+strconv.Itoa(group.Id)+"/movements", bytes.NewReader(body))
+			request = mux.SetURLVars(request, map[string]string{"groupId": strconv.Itoa(group.Id)})
+			response := httptest.NewRecorder()
+
+			AddMovementToGroup(response, request)
+
+			if response.Code != test.expectedStatus {
+				t.Errorf("got status %d, expected %d: %s", response.Code, test.expectedStatus, response.Body.String())
+			}
+		})
+	}
+}
+
+func TestAddTransferToGroupRejectsUnknownReferences(t *testing.T) {
+	ctx := context.Background()
+	group, err := model_api.CreateGroup(ctx, "Viaje")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	otherGroup, err := model_api.CreateGroup(ctx, "Otro viaje")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	ana, _ := model_api.AddParticipant(ctx, model_api.Participant{GroupId: group.Id, Name: "Ana"})
+	bruno, _ := model_api.AddParticipant(ctx, model_api.Participant{GroupId: group.Id, Name: "Bruno"})
+	carla, _ := model_api.AddParticipant(ctx, model_api.Participant{GroupId: otherGroup.Id, Name: "Carla"})
+
+	tests := []struct {
+		name              string
+		groupId           int
+		fromParticipantId int
+		expectedStatus    int
+	}{
+		{
+			name:              "Participants of the group",
+			groupId:           group.Id,
+			fromParticipantId: bruno.Id,
+			expectedStatus:    http.StatusOK,
+		},
+		{
+			name:              "Participant of another group",
+			groupId:           group.Id,
+			fromParticipantId: carla.Id,
+			expectedStatus:    http.StatusBadRequest,
+		},
+		{
+			name:              "Missing group",
+			groupId:           otherGroup.Id + 1000,
+			fromParticipantId: bruno.Id,
+			expectedStatus:    http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transfer := model_api.Transfer{
+				FromParticipantId: test.fromParticipantId,
+				ToParticipantId:   ana.Id,
+				Amount:            model.NewMoney(500, group.Currency),
+				Concept:           "Devolución",
+			}
+			body, err := json.Marshal(transfer)
+			if err != nil {
+				t.Fatalf("Failed to marshal transfer: %v", err)
+			}
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/groups/"+strconv.Itoa(test.groupId)+"/transfers", bytes.NewReader(body))
+			request = mux.SetURLVars(request, map[string]string{"groupId": strconv.Itoa(test.groupId)})
+			response := httptest.NewRecorder()
+
+			AddTransferToGroup(response, request)
+
+			if response.Code != test.expectedStatus {
+				t.Errorf("got status %d, expected %d: %s", response.Code, test.expectedStatus, response.Body.String())
+			}
+		})
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	model_api "github.com/vituchon/splitify/model/api"
 	"github.com/vituchon/splitify/presentation/web/controllers"
+	"github.com/vituchon/splitify/repositories"
 	"github.com/vituchon/splitify/util"
 
 	"github.com/gorilla/handlers"
@@ -39,6 +43,60 @@ func StartServer() {
 	}
 	controllers.InitSessionStore(key)
 
+	storageDir := getenv("STORAGE_DIR", "")
+	sqliteFilePath := getenv("SQLITE_FILE", "")
+	postgresDsn := getenv("POSTGRES_DSN", "")
+	storagesCount := 0
+	for _, setting := range []string{storageDir, sqliteFilePath, postgresDsn} {
+		if setting != "" {
+			storagesCount++
+		}
+	}
+	if storagesCount > 1 {
+		log.Println("Only one of STORAGE_DIR, SQLITE_FILE and POSTGRES_DSN may be set, refusing to start")
+		return
+	}
+	if storageDir != "" {
+		err = model_api.UseFileStorage(storageDir)
+		if err != nil {
+			log.Printf("Unexpected error while opening file storage: %v", err)
+			return
+		}
+	}
+	if sqliteFilePath != "" {
+		err = model_api.UseSqliteStorage(sqliteFilePath)
+		if err != nil {
+			log.Printf("Unexpected error while opening sqlite database: %v", err)
+			return
+		}
+	}
+	if postgresDsn != "" {
+		err = model_api.UsePostgresStorage(postgresDsn)
+		if err != nil {
+			log.Printf("Unexpected error while connecting to postgres database: %v", err)
+			return
+		}
+	}
+	defer closeStorage()
+	exchangeRatesFilePath := getenv("EXCHANGE_RATES_FILE", "")
+	if exchangeRatesFilePath != "" {
+		exchangeRates, err := repositories.NewExchangeRatesFileStorage(exchangeRatesFilePath)
+		if err != nil {
+			log.Printf("Unexpected error while reading exchange rates file: %v", err)
+			return
+		}
+		model_api.SetExchangeRateProvider(exchangeRates)
+	}
+	priceIndexFilePath := getenv("PRICE_INDEX_FILE", "")
+	if priceIndexFilePath != "" {
+		priceIndex, err := repositories.NewPriceIndexFileStorage(priceIndexFilePath)
+		if err != nil {
+			log.Printf("Unexpected error while reading price index file: %v", err)
+			return
+		}
+		model_api.SetPriceIndexProvider(priceIndex)
+	}
+
 	router := buildRouter()
 	port := getenv("PORT", "9999")
 	server := &http.Server{
@@ -47,13 +105,40 @@ func StartServer() {
 		ReadTimeout:  40 * time.Second,
 		WriteTimeout: 300 * time.Second,
 	}
+	shutdownDone := make(chan struct{})
+	go shutdownOnSignal(server, shutdownDone)
 	log.Printf("Splitify web server listening at port %v", server.Addr)
 	err = server.ListenAndServe()
-	if err != nil {
+	if err == http.ErrServerClosed {
+		<-shutdownDone // the requests in progress must finish before the storage is closed
+	} else if err != nil {
 		log.Println("Unexpected error initiliazing piedra papel y tijera web server: ", err)
 	}
+}
+
+const shutdownTimeout = 30 * time.Second
+
+// stops accepting requests on an interrupt or terminate signal and waits for the ones in progress to finish
+func shutdownOnSignal(server *http.Server, done chan<- struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	log.Println("Splitify web server shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		log.Printf("Unexpected error while shutting down web server: %v", err)
+	}
+	close(done)
+}
 
-	// TODO (for greater good) : Perhaps we are now in condition to add https://github.com/gorilla/mux#graceful-shutdown
+// releases the lock and log of the file storage or the database connections
+func closeStorage() {
+	err := model_api.CloseStorage()
+	if err != nil {
+		log.Printf("Unexpected error while closing storage: %v", err)
+	}
 }
 
 func getenv(key, fallback string) string {
@@ -83,12 +168,28 @@ func buildRouter() *mux.Router {
 	apiRouter := rootRouter.PathPrefix("/api/v1").Subrouter()
 	apiGet := BuildSetHandleFunc(apiRouter, "GET")
 	apiPost := BuildSetHandleFunc(apiRouter, "POST")
+	apiPut := BuildSetHandleFunc(apiRouter, "PUT")
 	//apiDelete := BuildSetHandleFunc(apiRouter, "DELETE")
 
 	apiGet("/groups", controllers.GetAllGroups)
 	apiPost("/groups", controllers.CreateGroup)
+	apiPut("/groups/{groupId:[0-9]+}/remainder-policy", controllers.UpdateGroupRemainderPolicy)
+	apiPut("/groups/{groupId:[0-9]+}/inflation-adjustment", controllers.UpdateGroupInflationAdjustment)
 	apiGet("/groups/{groupId:[0-9]+}/participants", controllers.GetGroupParticipants)
 	apiPost("/groups/{groupId:[0-9]+}/participants", controllers.AddParcipantToGroup)
+	apiPut("/groups/{groupId:[0-9]+}/participants/{participantId:[0-9]+}/weight", controllers.UpdateParticipantWeight)
+	apiPut("/groups/{groupId:[0-9]+}/participants/{participantId:[0-9]+}/presences", controllers.UpdateParticipantPresences)
+	apiGet("/groups/{groupId:[0-9]+}/movements", controllers.GetGroupMovements)
+	apiPost("/groups/{groupId:[0-9]+}/movements", controllers.AddMovementToGroup)
+	apiPost("/groups/{groupId:[0-9]+}/transfers", controllers.AddTransferToGroup)
+	apiGet("/groups/{groupId:[0-9]+}/balances", controllers.GetGroupBalances)
+	apiGet("/groups/{groupId:[0-9]+}/debts", controllers.GetGroupDebts)
+	apiGet("/groups/{groupId:[0-9]+}/balance-sheet", controllers.GetGroupBalanceSheet)
+	apiPut("/groups/{groupId:[0-9]+}/settlement-units", controllers.UpdateGroupSettlementUnits)
+	apiGet("/groups/{groupId:[0-9]+}/settlement/units", controllers.GetGroupUnitsSettlement)
+	apiGet("/groups/{groupId:[0-9]+}/settlement", controllers.GetGroupSettlement)
+	apiPost("/groups/{groupId:[0-9]+}/settlement", controllers.SettleUpGroup)
+	apiPost("/groups/{groupId:[0-9]+}/settlement/partial", controllers.SettleUpGroupPartially)
 	return router
 }
 
@@ -0,0 +1,315 @@
+package repositories
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// The behaviour every EntitiesRepository implementation must have, a new backend is verified by running it from its tests, e.g.
+//
+//	EntitiesRepositoryConformance[*model.Group]{
+//		NewRepository: func(t *testing.T) EntitiesRepository[*model.Group] { return NewEntitiesMemoryStorage[*model.Group]() },
+//		NewEntity:     func(seed int) *model.Group { return &model.Group{Name: fmt.Sprint(seed)} },
+//	}.Run(t)
+type EntitiesRepositoryConformance[E Identificable] struct {
+	NewRepository func(t *testing.T) EntitiesRepository[E]               // an empty repository
+	NewEntity     func(seed int) E                                       // a not saved entity, different seeds give different entities that are read back unchanged (as reflect.DeepEqual sees them)
+	LookUp        func(repo EntitiesRepository[E], key int) ([]E, error) // optional, the repository's own lookup (e.g. GetByGroupId), checked against KeyOf
+	KeyOf         func(entity E) int                                     // required along with LookUp, the value the entity is looked up by (e.g. its group id)
+}
+
+const conformanceConcurrentSaves = 50
+
+func (suite EntitiesRepositoryConformance[E]) Run(t *testing.T) {
+	t.Run("Save assigns new ids", suite.testSaveAssignsIds)
+	t.Run("GetById of a missing entity", suite.testGetByIdMiss)
+	t.Run("Update replaces the stored entity", suite.testUpdate)
+	t.Run("Update of a missing entity", suite.testUpdateMiss)
+	t.Run("Delete", suite.testDelete)
+	t.Run("Entities are not shared with the callers", suite.testEntitiesAreNotShared)
+	t.Run("Concurrent saves", suite.testConcurrentSaves)
+	if suite.LookUp != nil {
+		t.Run("Look up by key", suite.testLookUp)
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) testSaveAssignsIds(t *testing.T) {
+	repo := suite.NewRepository(t)
+	first, err := repo.Save(suite.NewEntity(1))
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	second, err := repo.Save(suite.NewEntity(2))
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	if first.GetId() <= 0 || second.GetId() <= 0 || first.GetId() == second.GetId() {
+		t.Fatalf("Expected distinct positive ids, got %v and %v", first.GetId(), second.GetId())
+	}
+
+	expected := suite.NewEntity(2)
+	expected.SetId(second.GetId())
+	got, err := repo.GetById(second.GetId())
+	if err != nil {
+		t.Fatalf("Failed to get entity: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v, expected %+v", got, expected)
+	}
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("Failed to get all entities: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 entities, got %d", len(all))
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) testGetByIdMiss(t *testing.T) {
+	repo := suite.NewRepository(t)
+	saved, err := repo.Save(suite.NewEntity(1))
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	_, err = repo.GetById(saved.GetId() + 1)
+	if err != EntityNotExistsErr {
+		t.Errorf("got error %v, expected %v", err, EntityNotExistsErr)
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) testUpdate(t *testing.T) {
+	repo := suite.NewRepository(t)
+	saved, err := repo.Save(suite.NewEntity(1))
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	changed := suite.NewEntity(2) // a different instance, so pointer entities mutated in place do not hide a lost update
+	changed.SetId(saved.GetId())
+	updated, err := repo.Update(changed)
+	if err != nil {
+		t.Fatalf("Failed to update entity: %v", err)
+	}
+	expected := suite.NewEntity(2)
+	expected.SetId(saved.GetId())
+	if !reflect.DeepEqual(updated, expected) {
+		t.Errorf("Update returned %+v, expected %+v", updated, expected)
+	}
+	got, err := repo.GetById(saved.GetId())
+	if err != nil {
+		t.Fatalf("Failed to get entity: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v, expected %+v", got, expected)
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) testUpdateMiss(t *testing.T) {
+	repo := suite.NewRepository(t)
+	missing := suite.NewEntity(1)
+	missing.SetId(1)
+	_, err := repo.Update(missing)
+	if err != EntityNotExistsErr {
+		t.Errorf("got error %v, expected %v", err, EntityNotExistsErr)
+	}
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("Failed to get all entities: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("Expected the update not to create the entity, got %v", all)
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) testDelete(t *testing.T) {
+	repo := suite.NewRepository(t)
+	deleted, err := repo.Save(suite.NewEntity(1))
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	kept, err := repo.Save(suite.NewEntity(2))
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	err = repo.Delete(deleted.GetId())
+	if err != nil {
+		t.Fatalf("Failed to delete entity: %v", err)
+	}
+	_, err = repo.GetById(deleted.GetId())
+	if err != EntityNotExistsErr {
+		t.Errorf("got error %v, expected %v", err, EntityNotExistsErr)
+	}
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("Failed to get all entities: %v", err)
+	}
+	if len(all) != 1 || all[0].GetId() != kept.GetId() {
+		t.Errorf("Expected only the entity %v to remain, got %v", kept.GetId(), all)
+	}
+	err = repo.Delete(deleted.GetId())
+	if err != nil {
+		t.Errorf("Deleting a missing entity must not fail, got %v", err)
+	}
+	saved, err := repo.Save(suite.NewEntity(3))
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	if saved.GetId() == deleted.GetId() || saved.GetId() == kept.GetId() {
+		t.Errorf("Expected the ids not to be reused, got %v", saved.GetId())
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) testEntitiesAreNotShared(t *testing.T) {
+	repo := suite.NewRepository(t)
+	entity := suite.NewEntity(1)
+	saved, err := repo.Save(entity)
+	if err != nil {
+		t.Fatalf("Failed to save entity: %v", err)
+	}
+	id := saved.GetId()
+	got, err := repo.GetById(id)
+	if err != nil {
+		t.Fatalf("Failed to get entity: %v", err)
+	}
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("Failed to get all entities: %v", err)
+	}
+	saved.SetId(id + 1) // changes made without updating must not reach the stored entity
+	got.SetId(id + 2)
+	all[0].SetId(id + 3)
+	stored, err := repo.GetById(id)
+	if err != nil {
+		t.Fatalf("Failed to get entity: %v", err)
+	}
+	if stored.GetId() != id {
+		t.Errorf("Expected the stored entity to keep the id %v, got %v", id, stored.GetId())
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) testConcurrentSaves(t *testing.T) {
+	repo := suite.NewRepository(t)
+	var wg sync.WaitGroup
+	ids := make(chan int, conformanceConcurrentSaves)
+	errs := make(chan error, conformanceConcurrentSaves)
+	for i := 0; i < conformanceConcurrentSaves; i++ {
+		wg.Add(1)
+		go func(seed int) {
+			defer wg.Done()
+			saved, err := repo.Save(suite.NewEntity(seed))
+			if err != nil {
+				errs <- err
+				return
+			}
+			ids <- saved.GetId()
+			_, err = repo.GetById(saved.GetId())
+			if err != nil {
+				errs <- err
+			}
+			_, err = repo.GetAll()
+			if err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Failed while saving concurrently: %v", err)
+	}
+	seen := make(map[int]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("The id %v was assigned twice", id)
+		}
+		seen[id] = true
+	}
+	all, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("Failed to get all entities: %v", err)
+	}
+	if len(all) != conformanceConcurrentSaves {
+		t.Errorf("Expected %d entities, got %d", conformanceConcurrentSaves, len(all))
+	}
+}
+
+const conformanceLookUpEntities = 6
+
+func (suite EntitiesRepositoryConformance[E]) testLookUp(t *testing.T) {
+	repo := suite.NewRepository(t)
+	idsByKey := make(map[int][]int)
+	for seed := 1; seed <= conformanceLookUpEntities; seed++ {
+		saved, err := repo.Save(suite.NewEntity(seed))
+		if err != nil {
+			t.Fatalf("Failed to save entity: %v", err)
+		}
+		idsByKey[suite.KeyOf(saved)] = append(idsByKey[suite.KeyOf(saved)], saved.GetId())
+	}
+	if len(idsByKey) < 2 {
+		t.Fatalf("Expected the entities to have different keys, NewEntity must vary them along the seeds")
+	}
+	suite.ensureLookUpFinds(t, repo, idsByKey)
+
+	// the entities of the first key are moved to another one or deleted, the lookups must follow
+	keys := sortedKeys(idsByKey)
+	ids := idsByKey[keys[0]]
+	for _, id := range ids[1:] {
+		err := repo.Delete(id)
+		if err != nil {
+			t.Fatalf("Failed to delete entity: %v", err)
+		}
+	}
+	seed := conformanceLookUpEntities + 1
+	for suite.KeyOf(suite.NewEntity(seed)) == keys[0] {
+		seed++
+	}
+	moved := suite.NewEntity(seed)
+	moved.SetId(ids[0])
+	_, err := repo.Update(moved)
+	if err != nil {
+		t.Fatalf("Failed to update entity: %v", err)
+	}
+	delete(idsByKey, keys[0])
+	idsByKey[suite.KeyOf(moved)] = append(idsByKey[suite.KeyOf(moved)], ids[0])
+	suite.ensureLookUpFinds(t, repo, idsByKey)
+	entities, err := suite.LookUp(repo, keys[0])
+	if err != nil {
+		t.Fatalf("Failed to look up entities: %v", err)
+	}
+	if len(entities) != 0 {
+		t.Errorf("Expected no entity for the key %v, got %v", keys[0], entities)
+	}
+}
+
+func (suite EntitiesRepositoryConformance[E]) ensureLookUpFinds(t *testing.T, repo EntitiesRepository[E], idsByKey map[int][]int) {
+	t.Helper()
+	for key, expectedIds := range idsByKey {
+		entities, err := suite.LookUp(repo, key)
+		if err != nil {
+			t.Fatalf("Failed to look up entities: %v", err)
+		}
+		ids := make([]int, 0, len(entities))
+		for _, entity := range entities {
+			if suite.KeyOf(entity) != key {
+				t.Errorf("Looking up the key %v gave the entity %+v", key, entity)
+			}
+			ids = append(ids, entity.GetId())
+		}
+		sort.Ints(ids)
+		sort.Ints(expectedIds)
+		if !reflect.DeepEqual(ids, expectedIds) {
+			t.Errorf("Looking up the key %v gave the ids %v, expected %v", key, ids, expectedIds)
+		}
+	}
+}
+
+func sortedKeys(idsByKey map[int][]int) []int {
+	keys := make([]int, 0, len(idsByKey))
+	for key := range idsByKey {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
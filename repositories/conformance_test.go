@@ -0,0 +1,285 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vituchon/splitify/model"
+)
+
+func newGroup(seed int) *model.Group {
+	return &model.Group{Name: fmt.Sprintf("Grupo %d", seed), Currency: model.DefaultCurrency}
+}
+
+func newParticipant(seed int) *model.Participant {
+	return &model.Participant{Name: fmt.Sprintf("Participante %d", seed), GroupId: seed%3 + 1, Weight: seed%2 + 1}
+}
+
+func newMovement(seed int) *model.Movement {
+	return &model.Movement{GroupId: seed%3 + 1, Concept: fmt.Sprintf("Movimiento %d", seed), Amount: seed * 100, Currency: model.DefaultCurrency, ExchangeRate: 1, Kind: model.ExpenseKind}
+}
+
+func newParticipantMovement(seed int) *model.ParticipantMovement {
+	return &model.ParticipantMovement{MovementId: seed%3 + 1, ParticipantId: seed, Amount: seed * 100, Weight: 1}
+}
+
+func newTestFileDatabase(t *testing.T) *FileDatabase {
+	database, err := OpenFileDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open file database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func newTestSqlStorage(t *testing.T) *SqlStorage {
+	db, err := OpenSqliteDatabase(filepath.Join(t.TempDir(), "splitify.db"))
+	if err != nil {
+		t.Fatalf("Failed to open sqlite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewSqlStorage(db, SqliteDialect{}, 0)
+}
+
+// runs only when SPLITIFY_TEST_POSTGRES_DSN points to a throwaway postgres database, as its tables are emptied first
+func newTestPostgresStorage(t *testing.T) *SqlStorage {
+	dsn := os.Getenv("SPLITIFY_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("SPLITIFY_TEST_POSTGRES_DSN is not set")
+	}
+	db, err := OpenPostgresDatabase(dsn)
+	if err != nil {
+		t.Fatalf("Failed to open postgres database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec("TRUNCATE groups, participants, movements, participant_movements RESTART IDENTITY")
+	if err != nil {
+		t.Fatalf("Failed to empty postgres database: %v", err)
+	}
+	return NewSqlStorage(db, PostgresDialect{}, 0)
+}
+
+func TestGroupsRepositoriesConformance(t *testing.T) {
+	newRepositories := map[string]func(t *testing.T) EntitiesRepository[*model.Group]{
+		"memory": func(t *testing.T) EntitiesRepository[*model.Group] {
+			return NewEntitiesMemoryStorage[*model.Group]()
+		},
+		"file": func(t *testing.T) EntitiesRepository[*model.Group] {
+			repo, err := NewEntitiesFileStorage[*model.Group](newTestFileDatabase(t), "groups")
+			if err != nil {
+				t.Fatalf("Failed to create file storage: %v", err)
+			}
+			return repo
+		},
+		"sqlite": func(t *testing.T) EntitiesRepository[*model.Group] {
+			return newTestSqlStorage(t).Repositories().Groups
+		},
+		"postgres": func(t *testing.T) EntitiesRepository[*model.Group] {
+			return newTestPostgresStorage(t).Repositories().Groups
+		},
+	}
+	for name, newRepository := range newRepositories {
+		t.Run(name, func(t *testing.T) {
+			EntitiesRepositoryConformance[*model.Group]{NewRepository: newRepository, NewEntity: newGroup}.Run(t)
+		})
+	}
+}
+
+func TestParticipantsRepositoriesConformance(t *testing.T) {
+	newRepositories := map[string]func(t *testing.T) EntitiesRepository[*model.Participant]{
+		"memory": func(t *testing.T) EntitiesRepository[*model.Participant] {
+			return NewParticipantsMemoryRepository()
+		},
+		"file": func(t *testing.T) EntitiesRepository[*model.Participant] {
+			repo, err := NewParticipantsFileRepository(newTestFileDatabase(t))
+			if err != nil {
+				t.Fatalf("Failed to create file storage: %v", err)
+			}
+			return repo
+		},
+		"sqlite": func(t *testing.T) EntitiesRepository[*model.Participant] {
+			return newTestSqlStorage(t).Repositories().Participants
+		},
+		"postgres": func(t *testing.T) EntitiesRepository[*model.Participant] {
+			return newTestPostgresStorage(t).Repositories().Participants
+		},
+	}
+	for name, newRepository := range newRepositories {
+		t.Run(name, func(t *testing.T) {
+			EntitiesRepositoryConformance[*model.Participant]{
+				NewRepository: newRepository,
+				NewEntity:     newParticipant,
+				LookUp: func(repo EntitiesRepository[*model.Participant], groupId int) ([]*model.Participant, error) {
+					return repo.(ParticipantsRepository).GetByGroupId(groupId)
+				},
+				KeyOf: func(participant *model.Participant) int { return participant.GroupId },
+			}.Run(t)
+		})
+	}
+}
+
+func TestMovementsRepositoriesConformance(t *testing.T) {
+	newRepositories := map[string]func(t *testing.T) EntitiesRepository[*model.Movement]{
+		"memory": func(t *testing.T) EntitiesRepository[*model.Movement] {
+			return NewMovementsMemoryRepository()
+		},
+		"file": func(t *testing.T) EntitiesRepository[*model.Movement] {
+			repo, err := NewMovementsFileRepository(newTestFileDatabase(t))
+			if err != nil {
+				t.Fatalf("Failed to create file storage: %v", err)
+			}
+			return repo
+		},
+		"sqlite": func(t *testing.T) EntitiesRepository[*model.Movement] {
+			return newTestSqlStorage(t).Repositories().Movements
+		},
+		"postgres": func(t *testing.T) EntitiesRepository[*model.Movement] {
+			return newTestPostgresStorage(t).Repositories().Movements
+		},
+	}
+	for name, newRepository := range newRepositories {
+		t.Run(name, func(t *testing.T) {
+			EntitiesRepositoryConformance[*model.Movement]{
+				NewRepository: newRepository,
+				NewEntity:     newMovement,
+				LookUp: func(repo EntitiesRepository[*model.Movement], groupId int) ([]*model.Movement, error) {
+					return repo.(MovementsRepository).GetByGroupId(groupId)
+				},
+				KeyOf: func(movement *model.Movement) int { return movement.GroupId },
+			}.Run(t)
+		})
+	}
+}
+
+func TestParticipantMovementsRepositoriesConformance(t *testing.T) {
+	newRepositories := map[string]func(t *testing.T) EntitiesRepository[*model.ParticipantMovement]{
+		"memory": func(t *testing.T) EntitiesRepository[*model.ParticipantMovement] {
+			return NewParticipantMovementsMemoryRepository()
+		},
+		"file": func(t *testing.T) EntitiesRepository[*model.ParticipantMovement] {
+			repo, err := NewParticipantMovementsFileRepository(newTestFileDatabase(t))
+			if err != nil {
+				t.Fatalf("Failed to create file storage: %v", err)
+			}
+			return repo
+		},
+		"sqlite": func(t *testing.T) EntitiesRepository[*model.ParticipantMovement] {
+			return newTestSqlStorage(t).Repositories().ParticipantMovements
+		},
+		"postgres": func(t *testing.T) EntitiesRepository[*model.ParticipantMovement] {
+			return newTestPostgresStorage(t).Repositories().ParticipantMovements
+		},
+	}
+	for name, newRepository := range newRepositories {
+		t.Run(name, func(t *testing.T) {
+			EntitiesRepositoryConformance[*model.ParticipantMovement]{
+				NewRepository: newRepository,
+				NewEntity:     newParticipantMovement,
+				LookUp: func(repo EntitiesRepository[*model.ParticipantMovement], movementId int) ([]*model.ParticipantMovement, error) {
+					return repo.(ParticipantMovementsRepository).GetByMovementId(movementId)
+				},
+				KeyOf: func(participantMovement *model.ParticipantMovement) int { return participantMovement.MovementId },
+			}.Run(t)
+		})
+	}
+}
+
+func newTestTransactionalStorages() map[string]func(t *testing.T) TransactionalStorage {
+	return map[string]func(t *testing.T) TransactionalStorage{
+		"memory": func(t *testing.T) TransactionalStorage {
+			return NewMemoryStorage()
+		},
+		"file": func(t *testing.T) TransactionalStorage {
+			storage, err := NewFileStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create file storage: %v", err)
+			}
+			t.Cleanup(func() { storage.Close() })
+			return storage
+		},
+		"sqlite": func(t *testing.T) TransactionalStorage {
+			return newTestSqlStorage(t)
+		},
+		"postgres": func(t *testing.T) TransactionalStorage {
+			return newTestPostgresStorage(t)
+		},
+	}
+}
+
+func TestTransactionalStoragesConformance(t *testing.T) {
+	for name, newStorage := range newTestTransactionalStorages() {
+		t.Run(name, func(t *testing.T) {
+			TransactionalStorageConformance{NewStorage: newStorage}.Run(t)
+		})
+	}
+}
+
+func TestSqlStorageQueriesAreCancelledWithTheirContext(t *testing.T) {
+	storage := newTestSqlStorage(t)
+	_, err := storage.Repositories().Groups.Save(newGroup(1))
+	if err != nil {
+		t.Fatalf("Failed to save group: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // e.g. the client went away before the request was served
+
+	_, err = storage.WithContext(ctx).Repositories().Groups.GetAll()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+	_, err = storage.WithContext(ctx).Begin()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+	_, err = storage.Repositories().Groups.GetAll()
+	if err != nil {
+		t.Errorf("Expected the storage without context to keep working, got %v", err)
+	}
+}
+
+var errTestConflict = errors.New("conflict with a concurrent transaction")
+
+// a sqlite dialect whose transactions fail because of a concurrent one when the work says so
+type conflictingDialect struct {
+	SqliteDialect
+}
+
+func (conflictingDialect) IsSerializationFailure(err error) bool {
+	return errors.Is(err, errTestConflict)
+}
+
+func TestSqlStorageRetriesTransactionsAbortedByConcurrentOnes(t *testing.T) {
+	storage := NewSqlStorage(newTestSqlStorage(t).db, conflictingDialect{}, 0)
+	attempts := 0
+	err := RunInTransaction(storage, func(repos Repositories) error {
+		attempts++
+		_, err := repos.Groups.Save(newGroup(attempts))
+		if err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return fmt.Errorf("could not save group: %w", errTestConflict)
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("Expected the transaction to succeed on its second attempt, got error %v after %d attempts", err, attempts)
+	}
+	groups, _ := storage.Repositories().Groups.GetAll()
+	if len(groups) != 1 || groups[0].Name != newGroup(2).Name {
+		t.Errorf("Expected only the group of the second attempt, got %v", groups)
+	}
+
+	attempts = 0
+	err = RunInTransaction(storage, func(repos Repositories) error {
+		attempts++
+		return errTestConflict
+	})
+	if err != errTestConflict || attempts != transactionAttempts {
+		t.Errorf("Expected to give up after %d attempts, got error %v after %d attempts", transactionAttempts, err, attempts)
+	}
+}
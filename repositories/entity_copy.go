@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"reflect"
+)
+
+// Copies the entity along with everything it points to (slices, maps and pointers), so the storages that keep their entities in
+// memory never share them with the callers: a caller changing what it got does not change the storage behind its back.
+// The entities must only have exported fields.
+func copyEntity[E Identificable](entity E) E {
+	return deepCopy(reflect.ValueOf(&entity).Elem()).Interface().(E)
+}
+
+func deepCopy(value reflect.Value) reflect.Value {
+	copied := reflect.New(value.Type()).Elem()
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			copied.Set(deepCopy(value.Elem()).Addr())
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			copied.Field(i).Set(deepCopy(value.Field(i)))
+		}
+	case reflect.Slice:
+		if !value.IsNil() {
+			copied.Set(reflect.MakeSlice(value.Type(), value.Len(), value.Len()))
+			for i := 0; i < value.Len(); i++ {
+				copied.Index(i).Set(deepCopy(value.Index(i)))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopy(value.Index(i)))
+		}
+	case reflect.Map:
+		if !value.IsNil() {
+			copied.Set(reflect.MakeMapWithSize(value.Type(), value.Len()))
+			iterator := value.MapRange()
+			for iterator.Next() {
+				copied.SetMapIndex(iterator.Key(), deepCopy(iterator.Value()))
+			}
+		}
+	default:
+		copied.Set(value)
+	}
+	return copied
+}
@@ -0,0 +1,546 @@
+package repositories
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const snapshotEveryRecords = 1000 // how many log records are appended before compacting them into a snapshot
+
+type fileLogOperation string
+
+const (
+	saveOperation   fileLogOperation = "save"
+	updateOperation fileLogOperation = "update"
+	deleteOperation fileLogOperation = "delete"
+	batchOperation  fileLogOperation = "batch" // the records of a committed transaction, written as a single line so they are kept or lost together
+)
+
+// a line of the append-only log, replaying it is idempotent so a record already included in a snapshot may be applied again
+type fileLogRecord struct {
+	Operation fileLogOperation `json:"op"`
+	Table     string           `json:"table,omitempty"`
+	Id        int              `json:"id,omitempty"`
+	Entity    json.RawMessage  `json:"entity,omitempty"`
+	Records   []fileLogRecord  `json:"records,omitempty"`
+}
+
+type fileSnapshotEntry struct {
+	Id     int             `json:"id"`
+	Entity json.RawMessage `json:"entity"`
+}
+
+type fileTableSnapshot struct {
+	IdSequence int                 `json:"idSequence"`
+	Entities   []fileSnapshotEntry `json:"entities"`
+}
+
+type fileSnapshot struct {
+	Tables map[string]fileTableSnapshot `json:"tables"`
+}
+
+// the state of a table as read from the files, it is decoded once the table's storage is created
+type rawFileTable struct {
+	idSequence   int
+	entitiesById map[int]json.RawMessage
+}
+
+type fileTable interface {
+	snapshot() (fileTableSnapshot, error)
+}
+
+// A directory holding the tables of several entities in a single append-only log file (one json record per line) that is fsynced
+// before a change is applied, so changes spanning several tables can be written atomically. Every snapshotEveryRecords records the
+// whole state is written to a snapshot file (written aside, fsynced and then renamed over the previous one) and the log is truncated.
+// On opening, the snapshot is loaded and the log replayed; a torn last record (e.g. the process died while appending it) is discarded.
+// The directory is locked while the database is open so no other process appends to the same log.
+type FileDatabase struct {
+	mutex         sync.Mutex
+	snapshotPath  string
+	logPath       string
+	lock          *os.File // holds the exclusive lock over the directory
+	log           *os.File
+	logRecords    int
+	broken        error // set when a failed append could not be undone, nothing else is appended after a partial record
+	tables        map[string]fileTable
+	rawTables     map[string]*rawFileTable
+	pending       []fileLogRecord // the records of the transaction in progress, if any
+	inTransaction bool
+}
+
+var DatabaseLockedErr error = errors.New("The database directory is in use by another process")
+
+func OpenFileDatabase(dir string) (*FileDatabase, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, "lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = lockFile(lock)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	database := &FileDatabase{
+		snapshotPath: filepath.Join(dir, "snapshot.json"),
+		logPath:      filepath.Join(dir, "log.jsonl"),
+		lock:         lock,
+		tables:       make(map[string]fileTable),
+		rawTables:    make(map[string]*rawFileTable),
+	}
+	err = database.load()
+	if err != nil {
+		lock.Close() // releases the lock
+		return nil, err
+	}
+	return database, nil
+}
+
+func (database *FileDatabase) load() error {
+	err := database.loadSnapshot()
+	if err != nil {
+		return err
+	}
+	err = database.replayLog()
+	if err != nil {
+		return err
+	}
+	database.log, err = os.OpenFile(database.logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	return err
+}
+
+func (database *FileDatabase) rawTable(name string) *rawFileTable {
+	table, exists := database.rawTables[name]
+	if !exists {
+		table = &rawFileTable{entitiesById: make(map[int]json.RawMessage)}
+		database.rawTables[name] = table
+	}
+	return table
+}
+
+func (database *FileDatabase) loadSnapshot() error {
+	data, err := os.ReadFile(database.snapshotPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snapshot fileSnapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return err
+	}
+	for name, tableSnapshot := range snapshot.Tables {
+		table := database.rawTable(name)
+		table.idSequence = tableSnapshot.IdSequence
+		for _, entry := range tableSnapshot.Entities {
+			table.entitiesById[entry.Id] = entry.Entity
+		}
+	}
+	return nil
+}
+
+func (database *FileDatabase) replayLog() error {
+	file, err := os.OpenFile(database.logPath, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	validLength := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				break // torn last record, it was never acknowledged so it is dropped
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var record fileLogRecord
+		err = json.Unmarshal(line, &record)
+		if err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				break // torn last record
+			}
+			return InvalidEntityStateErr // a corrupted record in the middle of the log can not be skipped safely
+		}
+		database.applyRaw(record)
+		database.logRecords++
+		validLength += int64(len(line))
+	}
+	err = file.Truncate(validLength)
+	if err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func (database *FileDatabase) applyRaw(record fileLogRecord) {
+	if record.Operation == batchOperation {
+		for _, batchRecord := range record.Records {
+			database.applyRaw(batchRecord)
+		}
+		return
+	}
+	table := database.rawTable(record.Table)
+	switch record.Operation {
+	case saveOperation, updateOperation:
+		table.entitiesById[record.Id] = record.Entity
+		if record.Id > table.idSequence {
+			table.idSequence = record.Id
+		}
+	case deleteOperation:
+		delete(table.entitiesById, record.Id)
+	}
+}
+
+// appends the record to the log and waits for it to reach the disk, inside a transaction it is kept until the commit
+func (database *FileDatabase) write(record fileLogRecord) error {
+	if database.inTransaction {
+		database.pending = append(database.pending, record)
+		return nil
+	}
+	return database.append(record)
+}
+
+// a failed append is cut off the log, otherwise the next record would follow a partial line that the replay can not skip
+func (database *FileDatabase) append(record fileLogRecord) error {
+	if database.broken != nil {
+		return database.broken
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	info, err := database.log.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = database.log.Write(append(line, '\n'))
+	if err == nil {
+		err = database.log.Sync()
+	}
+	if err != nil {
+		truncateErr := database.log.Truncate(info.Size())
+		if truncateErr != nil {
+			database.broken = truncateErr
+		}
+		return err
+	}
+	database.logRecords++
+	return nil
+}
+
+// compacts the log once the written changes were applied to the tables and no transaction is in progress. A failure is only logged
+// as the changes are already durable in the log, the compaction is attempted again after the next write.
+func (database *FileDatabase) compactIfNeeded() {
+	if database.inTransaction || database.logRecords < snapshotEveryRecords {
+		return
+	}
+	err := database.snapshot()
+	if err != nil {
+		log.Printf("error while compacting the log of the file database : '%v'", err)
+	}
+}
+
+// writes the whole state aside, fsyncs it and renames it over the previous snapshot, only then the log is truncated
+func (database *FileDatabase) snapshot() error {
+	snapshot := fileSnapshot{Tables: make(map[string]fileTableSnapshot)}
+	for name, table := range database.rawTables { // the ones without storage yet are kept as they were read
+		tableSnapshot := fileTableSnapshot{IdSequence: table.idSequence}
+		for id, entity := range table.entitiesById {
+			tableSnapshot.Entities = append(tableSnapshot.Entities, fileSnapshotEntry{Id: id, Entity: entity})
+		}
+		snapshot.Tables[name] = tableSnapshot
+	}
+	for name, table := range database.tables {
+		tableSnapshot, err := table.snapshot()
+		if err != nil {
+			return err
+		}
+		snapshot.Tables[name] = tableSnapshot
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmpPath := database.snapshotPath + ".tmp"
+	err = writeFileSynced(tmpPath, data)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpPath, database.snapshotPath)
+	if err != nil {
+		return err
+	}
+	err = syncDir(filepath.Dir(database.snapshotPath))
+	if err != nil {
+		return err
+	}
+	err = database.log.Truncate(0)
+	if err != nil {
+		return err
+	}
+	database.logRecords = 0
+	return database.log.Sync()
+}
+
+func writeFileSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+// holds the database until the transaction ends, the changes are applied to the tables right away but written only on commit
+func (database *FileDatabase) begin() {
+	database.mutex.Lock()
+	database.inTransaction = true
+	database.pending = nil
+}
+
+// writes the transaction's records as a single line, the caller must revert the tables when it fails
+func (database *FileDatabase) commit() error {
+	records := database.pending
+	database.inTransaction = false
+	database.pending = nil
+	if len(records) == 0 {
+		return nil
+	}
+	err := database.append(fileLogRecord{Operation: batchOperation, Records: records})
+	if err != nil {
+		return err
+	}
+	database.compactIfNeeded()
+	return nil
+}
+
+func (database *FileDatabase) end() {
+	database.inTransaction = false
+	database.pending = nil
+	database.mutex.Unlock()
+}
+
+// compacts the log into a snapshot and releases the log file and the directory, the log is still replayed on opening if the
+// compaction fails
+func (database *FileDatabase) Close() error {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	err := database.snapshot()
+	closeErr := database.log.Close()
+	unlockErr := database.lock.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return unlockErr
+}
+
+// closes the log and releases the directory without taking a snapshot, for a database that could not be fully opened
+func (database *FileDatabase) release() {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	database.log.Close()
+	database.lock.Close()
+}
+
+// A table of a FileDatabase, its entities are kept in memory (as EntitiesMemoryStorage does) while every change is made durable
+// through the database's log before being applied
+type EntitiesFileStorage[E Identificable] struct {
+	database     *FileDatabase
+	name         string
+	entitiesById map[int]E
+	idSequence   int
+}
+
+func NewEntitiesFileStorage[E Identificable](database *FileDatabase, name string) (*EntitiesFileStorage[E], error) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	repo := &EntitiesFileStorage[E]{database: database, name: name, entitiesById: make(map[int]E)}
+	if raw, exists := database.rawTables[name]; exists {
+		repo.idSequence = raw.idSequence
+		for id, data := range raw.entitiesById {
+			var entity E
+			err := json.Unmarshal(data, &entity)
+			if err != nil {
+				return nil, err
+			}
+			repo.entitiesById[id] = entity
+		}
+		delete(database.rawTables, name)
+	}
+	database.tables[name] = repo
+	return repo, nil
+}
+
+func (repo *EntitiesFileStorage[E]) snapshot() (fileTableSnapshot, error) {
+	snapshot := fileTableSnapshot{IdSequence: repo.idSequence, Entities: make([]fileSnapshotEntry, 0, len(repo.entitiesById))}
+	for id, entity := range repo.entitiesById {
+		data, err := json.Marshal(entity)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.Entities = append(snapshot.Entities, fileSnapshotEntry{Id: id, Entity: data})
+	}
+	return snapshot, nil
+}
+
+func (repo *EntitiesFileStorage[E]) GetAll() ([]E, error) {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	return repo.getAll(), nil
+}
+
+func (repo *EntitiesFileStorage[E]) getAll() []E {
+	entities := make([]E, 0, len(repo.entitiesById))
+	for _, entity := range repo.entitiesById {
+		entities = append(entities, copyEntity(entity))
+	}
+	return entities
+}
+
+func (repo *EntitiesFileStorage[E]) GetById(id int) (E, error) {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	entity, exists := repo.getById(id)
+	if !exists {
+		var zeroValue E
+		return zeroValue, EntityNotExistsErr
+	}
+	return entity, nil
+}
+
+func (repo *EntitiesFileStorage[E]) getById(id int) (E, bool) {
+	entity, exists := repo.entitiesById[id]
+	if !exists {
+		return entity, false
+	}
+	return copyEntity(entity), true
+}
+
+func (repo *EntitiesFileStorage[E]) Save(entity E) (E, error) {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	return repo.save(entity)
+}
+
+func (repo *EntitiesFileStorage[E]) save(entity E) (E, error) {
+	var zeroValue E
+	nextId := repo.idSequence + 1
+	entity.SetId(nextId)
+	err := repo.writeAndApply(saveOperation, nextId, entity)
+	if err != nil {
+		return zeroValue, err
+	}
+	return entity, nil
+}
+
+func (repo *EntitiesFileStorage[E]) Update(entity E) (E, error) {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	return repo.update(entity)
+}
+
+func (repo *EntitiesFileStorage[E]) update(entity E) (E, error) {
+	var zeroValue E
+	_, exists := repo.entitiesById[entity.GetId()]
+	if !exists {
+		return zeroValue, EntityNotExistsErr
+	}
+	err := repo.writeAndApply(updateOperation, entity.GetId(), entity)
+	if err != nil {
+		return zeroValue, err
+	}
+	return entity, nil
+}
+
+func (repo *EntitiesFileStorage[E]) Delete(id int) error {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	return repo.delete(id)
+}
+
+func (repo *EntitiesFileStorage[E]) delete(id int) error {
+	if _, exists := repo.entitiesById[id]; !exists {
+		return nil
+	}
+	var zeroValue E
+	return repo.writeAndApply(deleteOperation, id, zeroValue)
+}
+
+func (repo *EntitiesFileStorage[E]) writeAndApply(operation fileLogOperation, id int, entity E) error {
+	record := fileLogRecord{Operation: operation, Table: repo.name, Id: id}
+	if operation != deleteOperation {
+		data, err := json.Marshal(entity)
+		if err != nil {
+			return err
+		}
+		record.Entity = data
+	}
+	err := repo.database.write(record)
+	if err != nil {
+		return err
+	}
+	switch operation {
+	case saveOperation, updateOperation:
+		repo.entitiesById[id] = copyEntity(entity)
+		if id > repo.idSequence {
+			repo.idSequence = id
+		}
+	case deleteOperation:
+		delete(repo.entitiesById, id)
+	}
+	repo.database.compactIfNeeded()
+	return nil
+}
+
+func (repo *EntitiesFileStorage[E]) getIdSequence() int {
+	return repo.idSequence
+}
+
+func (repo *EntitiesFileStorage[E]) restore(id int, entity E, existed bool, idSequence int) {
+	if existed {
+		repo.entitiesById[id] = entity
+	} else {
+		delete(repo.entitiesById, id)
+	}
+	repo.idSequence = idSequence
+}
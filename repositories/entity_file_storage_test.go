@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vituchon/splitify/model"
+)
+
+func openTestGroups(t *testing.T, dir string) (*FileDatabase, *EntitiesFileStorage[*model.Group]) {
+	t.Helper()
+	database, err := OpenFileDatabase(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file database: %v", err)
+	}
+	groups, err := NewEntitiesFileStorage[*model.Group](database, "groups")
+	if err != nil {
+		t.Fatalf("Failed to create file storage: %v", err)
+	}
+	return database, groups
+}
+
+// releases the files as if the process died, the log is not compacted
+func crash(database *FileDatabase) {
+	database.log.Close()
+	database.lock.Close()
+}
+
+func logLength(t *testing.T, dir string) int64 {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(dir, "log.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to stat log: %v", err)
+	}
+	return info.Size()
+}
+
+func TestFileDatabaseDropsTornLastRecord(t *testing.T) {
+	dir := t.TempDir()
+	database, groups := openTestGroups(t, dir)
+	groups.Save(newGroup(1))
+	groups.Save(newGroup(2))
+	crash(database)
+	validLength := logLength(t, dir)
+
+	logFile, err := os.OpenFile(filepath.Join(dir, "log.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	logFile.WriteString(`{"op":"save","table":"groups","id":3,"entity":{"id":3,"na`)
+	logFile.Close()
+
+	database, groups = openTestGroups(t, dir)
+	defer database.Close()
+	if length := logLength(t, dir); length != validLength {
+		t.Errorf("Expected the torn record to be cut off the log, got length %d instead of %d", length, validLength)
+	}
+	all, _ := groups.GetAll()
+	if len(all) != 2 {
+		t.Fatalf("Expected the 2 acknowledged groups, got %v", all)
+	}
+	group, err := groups.Save(newGroup(3))
+	if err != nil || group.Id != 3 {
+		t.Errorf("Expected the id sequence to continue at 3, got %v (err %v)", group, err)
+	}
+}
+
+func TestFileDatabaseReplaysBatchRecords(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	err = RunInTransaction(storage, func(repos Repositories) error {
+		group, err := repos.Groups.Save(newGroup(1))
+		if err != nil {
+			return err
+		}
+		participant := newParticipant(1)
+		participant.GroupId = group.Id
+		_, err = repos.Participants.Save(participant)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+	crash(storage.database)
+
+	storage, err = NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer storage.Close()
+	groups, _ := storage.Repositories().Groups.GetAll()
+	participants, _ := storage.Repositories().Participants.GetByGroupId(1)
+	if len(groups) != 1 || len(participants) != 1 {
+		t.Errorf("Expected the transaction's group and participant to be replayed, got %v and %v", groups, participants)
+	}
+}
+
+func TestFileDatabaseSnapshotTruncatesLog(t *testing.T) {
+	dir := t.TempDir()
+	database, groups := openTestGroups(t, dir)
+	groups.Save(newGroup(1))
+	groups.Save(newGroup(2))
+	err := database.snapshot()
+	if err != nil {
+		t.Fatalf("Failed to snapshot: %v", err)
+	}
+	if length := logLength(t, dir); length != 0 {
+		t.Errorf("Expected the log to be truncated, got length %d", length)
+	}
+	groups.Save(newGroup(3))
+	crash(database)
+
+	database, groups = openTestGroups(t, dir)
+	defer database.Close()
+	all, _ := groups.GetAll()
+	if len(all) != 3 {
+		t.Errorf("Expected the snapshot and the log to be loaded, got %v", all)
+	}
+}
+
+func TestFileDatabaseDoesNotReuseIdsAfterCompaction(t *testing.T) {
+	dir := t.TempDir()
+	database, groups := openTestGroups(t, dir)
+	groups.Save(newGroup(1))
+	groups.Save(newGroup(2))
+	groups.Delete(2)
+	err := database.Close()
+	if err != nil {
+		t.Fatalf("Failed to close file database: %v", err)
+	}
+
+	database, groups = openTestGroups(t, dir)
+	defer database.Close()
+	group, err := groups.Save(newGroup(3))
+	if err != nil || group.Id != 3 {
+		t.Errorf("Expected the deleted id not to be reused, got %v (err %v)", group, err)
+	}
+}
+
+func TestFileDatabaseWriteSucceedsWhenCompactionFails(t *testing.T) {
+	dir := t.TempDir()
+	database, groups := openTestGroups(t, dir)
+	defer crash(database)
+	database.snapshotPath = filepath.Join(dir, "missing", "snapshot.json")
+	database.logRecords = snapshotEveryRecords - 1
+
+	group, err := groups.Save(newGroup(1))
+	if err != nil {
+		t.Fatalf("Expected the durable write to succeed, got %v", err)
+	}
+	_, err = groups.GetById(group.Id)
+	if err != nil {
+		t.Errorf("Expected the group to be applied, got %v", err)
+	}
+	if database.logRecords != snapshotEveryRecords {
+		t.Errorf("Expected the log to be kept for the next compaction, got %d records", database.logRecords)
+	}
+}
+
+func TestFileDatabaseLocksItsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	database, _ := openTestGroups(t, dir)
+	_, err := OpenFileDatabase(dir)
+	if err != DatabaseLockedErr {
+		t.Errorf("got error %v, expected %v", err, DatabaseLockedErr)
+	}
+	database.Close()
+
+	database, err = OpenFileDatabase(dir)
+	if err != nil {
+		t.Fatalf("Expected the directory to be released on close, got %v", err)
+	}
+	database.Close()
+}
+
+func TestFileStorageReleasesItsDirectoryWhenRecoveryFails(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "log.jsonl"), []byte(`{"op":"save","table":"participants","id":1,"entity":"not a participant"}`+"\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+	_, err = NewFileStorage(dir)
+	if err == nil {
+		t.Fatalf("Expected the participant not to be recovered")
+	}
+
+	_, err = NewFileStorage(dir)
+	if err == nil || err == DatabaseLockedErr {
+		t.Fatalf("Expected the directory to be released after the failed recovery, got error %v", err)
+	}
+	database, err := OpenFileDatabase(dir)
+	if err != nil {
+		t.Fatalf("Expected the directory to be released after the failed recovery, got %v", err)
+	}
+	database.Close()
+}
@@ -22,17 +22,21 @@ func NewEntitiesMemoryStorage[E Identificable]() *EntitiesMemoryStorage[E] {
 func (repo *EntitiesMemoryStorage[E]) GetAll() ([]E, error) {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
+	return repo.getAll(), nil
+}
+
+func (repo *EntitiesMemoryStorage[E]) getAll() []E {
 	entities := make([]E, 0, len(repo.entitiesById))
 	for _, entity := range repo.entitiesById {
-		entities = append(entities, entity)
+		entities = append(entities, copyEntity(entity))
 	}
-	return entities, nil
+	return entities
 }
 
 func (repo *EntitiesMemoryStorage[E]) GetById(id int) (E, error) {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
-	entity, exists := repo.entitiesById[id]
+	entity, exists := repo.getById(id)
 	if !exists {
 		var zeroValue E 
 		return zeroValue, EntityNotExistsErr
@@ -40,12 +44,24 @@ func (repo *EntitiesMemoryStorage[E]) GetById(id int) (E, error) {
 	return entity, nil
 }
 
+func (repo *EntitiesMemoryStorage[E]) getById(id int) (E, bool) {
+	entity, exists := repo.entitiesById[id]
+	if !exists {
+		return entity, false
+	}
+	return copyEntity(entity), true
+}
+
 func (repo *EntitiesMemoryStorage[E]) Save(entity E) (E, error) {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
+	return repo.save(entity)
+}
+
+func (repo *EntitiesMemoryStorage[E]) save(entity E) (E, error) {
 	nextId := repo.idSequence + 1
 	entity.SetId(nextId)
-	repo.entitiesById[nextId] = entity
+	repo.entitiesById[nextId] = copyEntity(entity)
 	repo.idSequence++
 	return entity, nil
 }
@@ -53,18 +69,39 @@ func (repo *EntitiesMemoryStorage[E]) Save(entity E) (E, error) {
 func (repo *EntitiesMemoryStorage[E]) Update(entity E) (E, error) {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
-	entity, exists := repo.entitiesById[entity.GetId()] 
+	return repo.update(entity)
+}
+
+func (repo *EntitiesMemoryStorage[E]) update(entity E) (E, error) {
+	_, exists := repo.entitiesById[entity.GetId()]
 	if !exists {
 		var zeroValue E 
 		return zeroValue, EntityNotExistsErr
 	}
-	repo.entitiesById[entity.GetId()] = entity
+	repo.entitiesById[entity.GetId()] = copyEntity(entity)
 	return entity, nil
 }
 
 func (repo *EntitiesMemoryStorage[E]) Delete(id int) error {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
+	return repo.delete(id)
+}
+
+func (repo *EntitiesMemoryStorage[E]) delete(id int) error {
 	delete(repo.entitiesById, id)
 	return nil
 }
+
+func (repo *EntitiesMemoryStorage[E]) getIdSequence() int {
+	return repo.idSequence
+}
+
+func (repo *EntitiesMemoryStorage[E]) restore(id int, entity E, existed bool, idSequence int) {
+	if existed {
+		repo.entitiesById[id] = entity
+	} else {
+		delete(repo.entitiesById, id)
+	}
+	repo.idSequence = idSequence
+}
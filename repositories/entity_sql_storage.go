@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Adapts the queries, written with "?" placeholders, to the database engine
+type SqlDialect interface {
+	Rebind(query string) string
+	// gives the statements taking and releasing a lock held by the connection while the migrations run, so processes starting
+	// at the same time do not apply them twice. Both are empty when the database serializes the migrations on its own.
+	MigrationsLock() (lock string, unlock string)
+	// tells whether the transaction failed because of a concurrent one, so running it again may succeed
+	IsSerializationFailure(err error) bool
+}
+
+// Runs the queries, either a *sql.DB or a *sql.Tx so the same repositories work inside a transaction
+type SqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Keeps each entity as a row with its id, the column it is looked up by (if any, e.g. group_id) and the entity itself encoded as json,
+// so the model can grow without a migration while the lookups are indexed queries.
+type EntitiesSqlStorage[E Identificable] struct {
+	db        SqlExecutor
+	dialect   SqlDialect
+	table     string
+	keyColumn string             // optional, e.g. "group_id"
+	keyOf     func(entity E) int // gives the value of the key column, required when there is one
+	ctx       context.Context    // optional, the queries are cancelled along with it
+	timeout   time.Duration      // optional, how long a query may take before it is cancelled
+}
+
+func NewEntitiesSqlStorage[E Identificable](db SqlExecutor, dialect SqlDialect, table string, keyColumn string, keyOf func(entity E) int) *EntitiesSqlStorage[E] {
+	return &EntitiesSqlStorage[E]{db: db, dialect: dialect, table: table, keyColumn: keyColumn, keyOf: keyOf}
+}
+
+// Makes the queries run within the given context (e.g. the one of the request being served) and each of them, when a timeout
+// is given, within that timeout
+func (repo *EntitiesSqlStorage[E]) SetContext(ctx context.Context, timeout time.Duration) {
+	repo.ctx = ctx
+	repo.timeout = timeout
+}
+
+func (repo *EntitiesSqlStorage[E]) newContext() (context.Context, context.CancelFunc) {
+	ctx := repo.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if repo.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.timeout)
+}
+
+func (repo *EntitiesSqlStorage[E]) GetAll() ([]E, error) {
+	return repo.query(fmt.Sprintf("SELECT id, data FROM %s ORDER BY id", repo.table))
+}
+
+func (repo *EntitiesSqlStorage[E]) GetById(id int) (E, error) {
+	var zeroValue E
+	query := repo.dialect.Rebind(fmt.Sprintf("SELECT id, data FROM %s WHERE id = ?", repo.table))
+	ctx, cancel := repo.newContext()
+	defer cancel()
+	entity, err := scanEntity[E](repo.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return zeroValue, EntityNotExistsErr
+	}
+	if err != nil {
+		return zeroValue, err
+	}
+	return entity, nil
+}
+
+// the entities whose key column has the given value
+func (repo *EntitiesSqlStorage[E]) GetByKey(key int) ([]E, error) {
+	return repo.query(fmt.Sprintf("SELECT id, data FROM %s WHERE %s = ? ORDER BY id", repo.table, repo.keyColumn), key)
+}
+
+func (repo *EntitiesSqlStorage[E]) Save(entity E) (E, error) {
+	var zeroValue E
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return zeroValue, err
+	}
+	ctx, cancel := repo.newContext()
+	defer cancel()
+	var id int
+	if repo.keyColumn == "" {
+		query := repo.dialect.Rebind(fmt.Sprintf("INSERT INTO %s (data) VALUES (?) RETURNING id", repo.table))
+		err = repo.db.QueryRowContext(ctx, query, string(data)).Scan(&id)
+	} else {
+		query := repo.dialect.Rebind(fmt.Sprintf("INSERT INTO %s (%s, data) VALUES (?, ?) RETURNING id", repo.table, repo.keyColumn))
+		err = repo.db.QueryRowContext(ctx, query, repo.keyOf(entity), string(data)).Scan(&id)
+	}
+	if err != nil {
+		return zeroValue, err
+	}
+	entity.SetId(id) // the stored data keeps the id it had, the id column prevails when reading it back
+	return entity, nil
+}
+
+func (repo *EntitiesSqlStorage[E]) Update(entity E) (E, error) {
+	var zeroValue E
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return zeroValue, err
+	}
+	ctx, cancel := repo.newContext()
+	defer cancel()
+	var result sql.Result
+	if repo.keyColumn == "" {
+		query := repo.dialect.Rebind(fmt.Sprintf("UPDATE %s SET data = ? WHERE id = ?", repo.table))
+		result, err = repo.db.ExecContext(ctx, query, string(data), entity.GetId())
+	} else {
+		query := repo.dialect.Rebind(fmt.Sprintf("UPDATE %s SET %s = ?, data = ? WHERE id = ?", repo.table, repo.keyColumn))
+		result, err = repo.db.ExecContext(ctx, query, repo.keyOf(entity), string(data), entity.GetId())
+	}
+	if err != nil {
+		return zeroValue, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return zeroValue, err
+	}
+	if affected == 0 {
+		return zeroValue, EntityNotExistsErr
+	}
+	return entity, nil
+}
+
+func (repo *EntitiesSqlStorage[E]) Delete(id int) error {
+	query := repo.dialect.Rebind(fmt.Sprintf("DELETE FROM %s WHERE id = ?", repo.table))
+	ctx, cancel := repo.newContext()
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (repo *EntitiesSqlStorage[E]) query(query string, args ...any) ([]E, error) {
+	ctx, cancel := repo.newContext()
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx, repo.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := make([]E, 0)
+	for rows.Next() {
+		entity, err := scanEntity[E](rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntity[E Identificable](row rowScanner) (E, error) {
+	var entity E
+	var id int
+	var data string
+	err := row.Scan(&id, &data)
+	if err != nil {
+		return entity, err
+	}
+	err = json.Unmarshal([]byte(data), &entity)
+	if err != nil {
+		return entity, err
+	}
+	entity.SetId(id)
+	return entity, nil
+}
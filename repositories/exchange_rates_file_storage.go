@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/vituchon/splitify/model"
+)
+
+const exchangeRateDateLayout = "2006-01-02"
+
+type exchangeRateRecord struct {
+	From model.Currency `json:"from"`
+	To   model.Currency `json:"to"`
+	Date string         `json:"date"` // the day since the rate applies, e.g. "2024-01-15"
+	Rate float64        `json:"rate"` // how many units of "to" one unit of "from" is worth
+}
+
+type exchangeRate struct {
+	since time.Time
+	rate  float64
+}
+
+type currencyPair struct {
+	from model.Currency
+	to   model.Currency
+}
+
+// Exchange rates read once from a json file, so they are available without network access. The expected format is
+//
+//	{"rates": [{"from": "USD", "to": "ARS", "date": "2024-01-15", "rate": 1050.5}, ...]}
+//
+// The rate used for a moment is the latest one dated on or before it, the inverse rate is used when only the opposite pair is given.
+type ExchangeRatesFileStorage struct {
+	ratesByPair map[currencyPair][]exchangeRate
+}
+
+var _ model.ExchangeRateProvider = (*ExchangeRatesFileStorage)(nil)
+
+func NewExchangeRatesFileStorage(filepath string) (*ExchangeRatesFileStorage, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	var content struct {
+		Rates []exchangeRateRecord `json:"rates"`
+	}
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &ExchangeRatesFileStorage{ratesByPair: make(map[currencyPair][]exchangeRate)}
+	for _, record := range content.Rates {
+		if model.EnsureCurrencyIsValid(record.From) != nil || model.EnsureCurrencyIsValid(record.To) != nil || model.EnsureExchangeRateIsValid(record.Rate) != nil {
+			return nil, InvalidEntityStateErr
+		}
+		since, err := time.Parse(exchangeRateDateLayout, record.Date)
+		if err != nil {
+			return nil, err
+		}
+		pair := currencyPair{from: record.From, to: record.To}
+		storage.ratesByPair[pair] = append(storage.ratesByPair[pair], exchangeRate{since: since, rate: record.Rate})
+	}
+	return storage, nil
+}
+
+func (storage *ExchangeRatesFileStorage) GetRate(from model.Currency, to model.Currency, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	rate, found := latestRateSince(storage.ratesByPair[currencyPair{from: from, to: to}], at)
+	if found {
+		return rate, nil
+	}
+	rate, found = latestRateSince(storage.ratesByPair[currencyPair{from: to, to: from}], at)
+	if found {
+		return 1 / rate, nil
+	}
+	return 0, model.ErrExchangeRateNotFound
+}
+
+func latestRateSince(rates []exchangeRate, at time.Time) (float64, bool) {
+	var latest *exchangeRate
+	for i := range rates {
+		if !rates[i].since.After(at) && (latest == nil || rates[i].since.After(latest.since)) {
+			latest = &rates[i]
+		}
+	}
+	if latest == nil {
+		return 0, false
+	}
+	return latest.rate, true
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vituchon/splitify/model"
+)
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func day(date string) time.Time {
+	at, _ := time.Parse("2006-01-02", date)
+	return at
+}
+
+func TestExchangeRatesFileStorageGetRate(t *testing.T) {
+	path := writeTestFile(t, "rates.json", `{"rates": [
+		{"from": "USD", "to": "ARS", "date": "2024-01-15", "rate": 1000},
+		{"from": "USD", "to": "ARS", "date": "2024-02-01", "rate": 1250},
+		{"from": "EUR", "to": "ARS", "date": "2024-01-01", "rate": 1100.5}
+	]}`)
+	storage, err := NewExchangeRatesFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to read exchange rates: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		from          model.Currency
+		to            model.Currency
+		at            time.Time
+		expectedRate  float64
+		expectedError error
+	}{
+		{name: "Same currency", from: "JPY", to: "JPY", at: day("2024-01-20"), expectedRate: 1},
+		{name: "On the day the rate applies", from: "USD", to: "ARS", at: day("2024-01-15"), expectedRate: 1000},
+		{name: "Latest earlier rate", from: "USD", to: "ARS", at: day("2024-01-31"), expectedRate: 1000},
+		{name: "After the latest rate", from: "USD", to: "ARS", at: day("2024-06-01"), expectedRate: 1250},
+		{name: "Inverse of the opposite pair", from: "ARS", to: "USD", at: day("2024-03-01"), expectedRate: 1.0 / 1250},
+		{name: "Before the first rate", from: "USD", to: "ARS", at: day("2024-01-14"), expectedError: model.ErrExchangeRateNotFound},
+		{name: "Missing currency pair", from: "USD", to: "EUR", at: day("2024-03-01"), expectedError: model.ErrExchangeRateNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rate, err := storage.GetRate(test.from, test.to, test.at)
+			if err != test.expectedError {
+				t.Fatalf("got error %v, expected %v", err, test.expectedError)
+			}
+			if rate != test.expectedRate {
+				t.Errorf("got rate %v, expected %v", rate, test.expectedRate)
+			}
+		})
+	}
+}
+
+func TestNewExchangeRatesFileStorageRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError error // nil when any error will do
+	}{
+		{name: "Zero rate", content: `{"rates": [{"from": "USD", "to": "ARS", "date": "2024-01-15", "rate": 0}]}`, expectedError: InvalidEntityStateErr},
+		{name: "Negative rate", content: `{"rates": [{"from": "USD", "to": "ARS", "date": "2024-01-15", "rate": -1000}]}`, expectedError: InvalidEntityStateErr},
+		{name: "Missing rate", content: `{"rates": [{"from": "USD", "to": "ARS", "date": "2024-01-15"}]}`, expectedError: InvalidEntityStateErr},
+		{name: "Invalid currency", content: `{"rates": [{"from": "usd", "to": "ARS", "date": "2024-01-15", "rate": 1000}]}`, expectedError: InvalidEntityStateErr},
+		{name: "Invalid date", content: `{"rates": [{"from": "USD", "to": "ARS", "date": "15/01/2024", "rate": 1000}]}`},
+		{name: "Not json", content: `USD,ARS,2024-01-15,1000`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewExchangeRatesFileStorage(writeTestFile(t, "rates.json", test.content))
+			if err == nil || (test.expectedError != nil && err != test.expectedError) {
+				t.Errorf("got error %v, expected %v", err, test.expectedError)
+			}
+		})
+	}
+
+	_, err := NewExchangeRatesFileStorage(filepath.Join(t.TempDir(), "missing.json"))
+	if !os.IsNotExist(err) {
+		t.Errorf("got error %v, expected the file not to exist", err)
+	}
+}
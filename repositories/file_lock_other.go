@@ -0,0 +1,12 @@
+//go:build !unix
+
+package repositories
+
+import (
+	"os"
+)
+
+// there are no advisory locks without unix, the directory must not be shared by several processes
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package repositories
+
+import (
+	"os"
+	"syscall"
+)
+
+// takes an exclusive advisory lock over the file without waiting, it is released when the file is closed or the process dies
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return DatabaseLockedErr
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/vituchon/splitify/model"
+)
+
+// The file repositories of every entity sharing a FileDatabase, a transaction holds the database until it finishes and writes
+// all its changes in a single log record on commit
+type FileStorage struct {
+	database             *FileDatabase
+	groups               *EntitiesFileStorage[*model.Group]
+	participants         *ParticipantsFileRepository
+	movements            *MovementsFileRepository
+	participantMovements *ParticipantMovementsFileRepository
+}
+
+var _ TransactionalStorage = (*FileStorage)(nil)
+
+func NewFileStorage(dir string) (*FileStorage, error) {
+	database, err := OpenFileDatabase(dir)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := newFileStorage(database)
+	if err != nil {
+		database.release() // without a snapshot, the tables not loaded yet would be missing from it
+		return nil, err
+	}
+	return storage, nil
+}
+
+func newFileStorage(database *FileDatabase) (*FileStorage, error) {
+	var err error
+	storage := &FileStorage{database: database}
+	storage.groups, err = NewEntitiesFileStorage[*model.Group](database, "groups")
+	if err != nil {
+		return nil, err
+	}
+	storage.participants, err = NewParticipantsFileRepository(database)
+	if err != nil {
+		return nil, err
+	}
+	storage.movements, err = NewMovementsFileRepository(database)
+	if err != nil {
+		return nil, err
+	}
+	storage.participantMovements, err = NewParticipantMovementsFileRepository(database)
+	if err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
+
+func (storage *FileStorage) Repositories() Repositories {
+	return Repositories{
+		Groups:               storage.groups,
+		Participants:         storage.participants,
+		Movements:            storage.movements,
+		ParticipantMovements: storage.participantMovements,
+	}
+}
+
+// the changes are written as soon as they are made, there is nothing to cancel
+func (storage *FileStorage) WithContext(ctx context.Context) TransactionalStorage {
+	return storage
+}
+
+func (storage *FileStorage) Begin() (Transaction, error) {
+	storage.database.begin()
+	return &fileTransaction{storage: storage, log: &undoLog{}}, nil
+}
+
+func (storage *FileStorage) Close() error {
+	return storage.database.Close()
+}
+
+type fileTransaction struct {
+	storage  *FileStorage
+	log      *undoLog
+	finished bool
+}
+
+func (tx *fileTransaction) Repositories() Repositories {
+	return Repositories{
+		Groups: undoLogRepository[*model.Group]{storage: tx.storage.groups, log: tx.log},
+		Participants: participantsUndoLogRepository{
+			undoLogRepository: undoLogRepository[*model.Participant]{storage: tx.storage.participants, log: tx.log},
+			getByGroupId:      tx.storage.participants.getByGroupId,
+		},
+		Movements: movementsUndoLogRepository{
+			undoLogRepository: undoLogRepository[*model.Movement]{storage: tx.storage.movements, log: tx.log},
+			getByGroupId:      tx.storage.movements.getByGroupId,
+		},
+		ParticipantMovements: participantMovementsUndoLogRepository{
+			undoLogRepository: undoLogRepository[*model.ParticipantMovement]{storage: tx.storage.participantMovements, log: tx.log},
+			getByMovementId:   tx.storage.participantMovements.getByMovementId,
+		},
+	}
+}
+
+func (tx *fileTransaction) Commit() error {
+	if tx.finished {
+		return TransactionFinishedErr
+	}
+	tx.finished = true
+	defer tx.storage.database.end()
+	err := tx.storage.database.commit()
+	if err != nil {
+		tx.log.undo()
+	}
+	return err
+}
+
+func (tx *fileTransaction) Rollback() error {
+	if tx.finished {
+		return TransactionFinishedErr
+	}
+	tx.finished = true
+	defer tx.storage.database.end()
+	tx.log.undo()
+	return nil
+}
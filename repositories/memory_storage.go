@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/vituchon/splitify/model"
+)
+
+// The memory repositories of every entity, a transaction holds all of them locked until it finishes and reverts its changes
+// with an undo log when rolled back
+type MemoryStorage struct {
+	groups               *EntitiesMemoryStorage[*model.Group]
+	participants         *ParticipantsMemoryRepository
+	movements            *MovementsMemoryRepository
+	participantMovements *ParticipantMovementsMemoryRepository
+}
+
+var _ TransactionalStorage = (*MemoryStorage)(nil)
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		groups:               NewEntitiesMemoryStorage[*model.Group](),
+		participants:         NewParticipantsMemoryRepository(),
+		movements:            NewMovementsMemoryRepository(),
+		participantMovements: NewParticipantMovementsMemoryRepository(),
+	}
+}
+
+func (storage *MemoryStorage) Repositories() Repositories {
+	return Repositories{
+		Groups:               storage.groups,
+		Participants:         storage.participants,
+		Movements:            storage.movements,
+		ParticipantMovements: storage.participantMovements,
+	}
+}
+
+// there is nothing to cancel in memory
+func (storage *MemoryStorage) WithContext(ctx context.Context) TransactionalStorage {
+	return storage
+}
+
+func (storage *MemoryStorage) Begin() (Transaction, error) {
+	// always in the same order, so concurrent transactions can not deadlock
+	storage.groups.mutex.Lock()
+	storage.participants.mutex.Lock()
+	storage.movements.mutex.Lock()
+	storage.participantMovements.mutex.Lock()
+	return &memoryTransaction{storage: storage, log: &undoLog{}}, nil
+}
+
+type memoryTransaction struct {
+	storage  *MemoryStorage
+	log      *undoLog
+	finished bool
+}
+
+func (tx *memoryTransaction) Repositories() Repositories {
+	return Repositories{
+		Groups: undoLogRepository[*model.Group]{storage: tx.storage.groups, log: tx.log},
+		Participants: participantsUndoLogRepository{
+			undoLogRepository: undoLogRepository[*model.Participant]{storage: tx.storage.participants, log: tx.log},
+			getByGroupId:      tx.storage.participants.getByGroupId,
+		},
+		Movements: movementsUndoLogRepository{
+			undoLogRepository: undoLogRepository[*model.Movement]{storage: tx.storage.movements, log: tx.log},
+			getByGroupId:      tx.storage.movements.getByGroupId,
+		},
+		ParticipantMovements: participantMovementsUndoLogRepository{
+			undoLogRepository: undoLogRepository[*model.ParticipantMovement]{storage: tx.storage.participantMovements, log: tx.log},
+			getByMovementId:   tx.storage.participantMovements.getByMovementId,
+		},
+	}
+}
+
+func (tx *memoryTransaction) Commit() error {
+	return tx.finish(false)
+}
+
+func (tx *memoryTransaction) Rollback() error {
+	return tx.finish(true)
+}
+
+func (tx *memoryTransaction) finish(undo bool) error {
+	if tx.finished {
+		return TransactionFinishedErr
+	}
+	tx.finished = true
+	if undo {
+		tx.log.undo()
+	}
+	tx.storage.participantMovements.mutex.Unlock()
+	tx.storage.movements.mutex.Unlock()
+	tx.storage.participants.mutex.Unlock()
+	tx.storage.groups.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"github.com/vituchon/splitify/model"
+)
+
+type MovementsFileRepository struct {
+	*EntitiesFileStorage[*model.Movement]
+}
+
+func NewMovementsFileRepository(database *FileDatabase) (*MovementsFileRepository, error) {
+	storage, err := NewEntitiesFileStorage[*model.Movement](database, "movements")
+	if err != nil {
+		return nil, err
+	}
+	return &MovementsFileRepository{EntitiesFileStorage: storage}, nil
+}
+
+func (repo *MovementsFileRepository) GetByGroupId(groupId int) ([]*model.Movement, error) {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	return repo.getByGroupId(groupId), nil
+}
+
+func (repo *MovementsFileRepository) getByGroupId(groupId int) []*model.Movement {
+	var movements []*model.Movement
+	for _, movement := range repo.entitiesById {
+		if movement.GroupId == groupId {
+			movements = append(movements, copyEntity(movement))
+		}
+	}
+	return movements
+}
@@ -23,12 +23,15 @@ func NewMovementsMemoryRepository() *MovementsMemoryRepository {
 func (repo *MovementsMemoryRepository) GetByGroupId(groupId int) ([]*model.Movement, error) {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
+	return repo.getByGroupId(groupId), nil
+}
 
+func (repo *MovementsMemoryRepository) getByGroupId(groupId int) []*model.Movement {
 	var movements []*model.Movement
 	for _, movement := range repo.entitiesById {
 		if movement.GroupId == groupId { 
-			movements = append(movements, movement)
+			movements = append(movements, copyEntity(movement))
 		}
 	}
-	return movements, nil
+	return movements
 }
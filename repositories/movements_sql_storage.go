@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"github.com/vituchon/splitify/model"
+)
+
+type MovementsSqlRepository struct {
+	*EntitiesSqlStorage[*model.Movement]
+}
+
+func NewMovementsSqlRepository(db SqlExecutor, dialect SqlDialect) *MovementsSqlRepository {
+	keyOf := func(movement *model.Movement) int {
+		return movement.GroupId
+	}
+	return &MovementsSqlRepository{
+		EntitiesSqlStorage: NewEntitiesSqlStorage(db, dialect, "movements", "group_id", keyOf),
+	}
+}
+
+func (repo *MovementsSqlRepository) GetByGroupId(groupId int) ([]*model.Movement, error) {
+	return repo.GetByKey(groupId)
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"github.com/vituchon/splitify/model"
+)
+
+type ParticipantMovementsFileRepository struct {
+	*EntitiesFileStorage[*model.ParticipantMovement]
+}
+
+func NewParticipantMovementsFileRepository(database *FileDatabase) (*ParticipantMovementsFileRepository, error) {
+	storage, err := NewEntitiesFileStorage[*model.ParticipantMovement](database, "participant_movements")
+	if err != nil {
+		return nil, err
+	}
+	return &ParticipantMovementsFileRepository{EntitiesFileStorage: storage}, nil
+}
+
+func (repo *ParticipantMovementsFileRepository) GetByMovementId(movementId int) ([]*model.ParticipantMovement, error) {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	return repo.getByMovementId(movementId), nil
+}
+
+func (repo *ParticipantMovementsFileRepository) getByMovementId(movementId int) []*model.ParticipantMovement {
+	var participantMovements []*model.ParticipantMovement
+	for _, participantMovement := range repo.entitiesById {
+		if participantMovement.MovementId == movementId {
+			participantMovements = append(participantMovements, copyEntity(participantMovement))
+		}
+	}
+	return participantMovements
+}
@@ -24,12 +24,15 @@ func NewParticipantMovementsMemoryRepository() *ParticipantMovementsMemoryReposi
 func (repo *ParticipantMovementsMemoryRepository) GetByMovementId(movementId int) ([]*model.ParticipantMovement, error) {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
+	return repo.getByMovementId(movementId), nil
+}
 
+func (repo *ParticipantMovementsMemoryRepository) getByMovementId(movementId int) []*model.ParticipantMovement {
 	var participantMovements []*model.ParticipantMovement
 	for _, participantMovement := range repo.entitiesById {
 		if participantMovement.MovementId == movementId { 
-			participantMovements = append(participantMovements, participantMovement)
+			participantMovements = append(participantMovements, copyEntity(participantMovement))
 		}
 	}
-	return participantMovements, nil
+	return participantMovements
 }
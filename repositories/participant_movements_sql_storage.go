@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"github.com/vituchon/splitify/model"
+)
+
+type ParticipantMovementsSqlRepository struct {
+	*EntitiesSqlStorage[*model.ParticipantMovement]
+}
+
+func NewParticipantMovementsSqlRepository(db SqlExecutor, dialect SqlDialect) *ParticipantMovementsSqlRepository {
+	keyOf := func(participantMovement *model.ParticipantMovement) int {
+		return participantMovement.MovementId
+	}
+	return &ParticipantMovementsSqlRepository{
+		EntitiesSqlStorage: NewEntitiesSqlStorage(db, dialect, "participant_movements", "movement_id", keyOf),
+	}
+}
+
+func (repo *ParticipantMovementsSqlRepository) GetByMovementId(movementId int) ([]*model.ParticipantMovement, error) {
+	return repo.GetByKey(movementId)
+}
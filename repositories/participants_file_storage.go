@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"github.com/vituchon/splitify/model"
+)
+
+type ParticipantsFileRepository struct {
+	*EntitiesFileStorage[*model.Participant]
+}
+
+func NewParticipantsFileRepository(database *FileDatabase) (*ParticipantsFileRepository, error) {
+	storage, err := NewEntitiesFileStorage[*model.Participant](database, "participants")
+	if err != nil {
+		return nil, err
+	}
+	return &ParticipantsFileRepository{EntitiesFileStorage: storage}, nil
+}
+
+func (repo *ParticipantsFileRepository) GetByGroupId(groupId int) ([]*model.Participant, error) {
+	repo.database.mutex.Lock()
+	defer repo.database.mutex.Unlock()
+	return repo.getByGroupId(groupId), nil
+}
+
+func (repo *ParticipantsFileRepository) getByGroupId(groupId int) []*model.Participant {
+	var participants []*model.Participant
+	for _, participant := range repo.entitiesById {
+		if participant.GroupId == groupId {
+			participants = append(participants, copyEntity(participant))
+		}
+	}
+	return participants
+}
@@ -23,12 +23,15 @@ func NewParticipantsMemoryRepository() *ParticipantsMemoryRepository {
 func (repo *ParticipantsMemoryRepository) GetByGroupId(groupId int) ([]*model.Participant, error) {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
+	return repo.getByGroupId(groupId), nil
+}
 
+func (repo *ParticipantsMemoryRepository) getByGroupId(groupId int) []*model.Participant {
 	var participants []*model.Participant
 	for _, participant := range repo.entitiesById {
 		if participant.GroupId == groupId { 
-			participants = append(participants, participant)
+			participants = append(participants, copyEntity(participant))
 		}
 	}
-	return participants, nil
+	return participants
 }
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"github.com/vituchon/splitify/model"
+)
+
+type ParticipantsSqlRepository struct {
+	*EntitiesSqlStorage[*model.Participant]
+}
+
+func NewParticipantsSqlRepository(db SqlExecutor, dialect SqlDialect) *ParticipantsSqlRepository {
+	keyOf := func(participant *model.Participant) int {
+		return participant.GroupId
+	}
+	return &ParticipantsSqlRepository{
+		EntitiesSqlStorage: NewEntitiesSqlStorage(db, dialect, "participants", "group_id", keyOf),
+	}
+}
+
+func (repo *ParticipantsSqlRepository) GetByGroupId(groupId int) ([]*model.Participant, error) {
+	return repo.GetByKey(groupId)
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vituchon/splitify/model"
+)
+
+const priceIndexMonthLayout = "2006-01"
+
+type priceIndexRecord struct {
+	Month string  `json:"month"` // e.g. "2024-01"
+	Value float64 `json:"value"`
+}
+
+// Monthly price index read once from a local file, either a json like
+//
+//	{"indexes": [{"month": "2024-01", "value": 4483.5}, ...]}
+//
+// or a csv (when the file has the .csv extension) with a "month,value" row per month, an optional header row is skipped.
+// When a month is missing (e.g. the current one is not published yet) the latest previous month is used.
+type PriceIndexFileStorage struct {
+	months       []string // sorted
+	valueByMonth map[string]float64
+}
+
+var _ model.PriceIndexProvider = (*PriceIndexFileStorage)(nil)
+
+func NewPriceIndexFileStorage(path string) (*PriceIndexFileStorage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []priceIndexRecord
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		records, err = parsePriceIndexCsv(data)
+	} else {
+		var content struct {
+			Indexes []priceIndexRecord `json:"indexes"`
+		}
+		err = json.Unmarshal(data, &content)
+		records = content.Indexes
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &PriceIndexFileStorage{valueByMonth: make(map[string]float64)}
+	for _, record := range records {
+		_, err := time.Parse(priceIndexMonthLayout, record.Month)
+		if err != nil {
+			return nil, err
+		}
+		if record.Value <= 0 {
+			return nil, model.ErrInvalidPriceIndex
+		}
+		if _, exists := storage.valueByMonth[record.Month]; exists {
+			return nil, DuplicatedEntityErr
+		}
+		storage.valueByMonth[record.Month] = record.Value
+		storage.months = append(storage.months, record.Month)
+	}
+	sort.Strings(storage.months)
+	return storage, nil
+}
+
+func parsePriceIndexCsv(data []byte) ([]priceIndexRecord, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]priceIndexRecord, 0, len(rows))
+	for i, row := range rows {
+		if len(row) != 2 {
+			return nil, InvalidEntityStateErr
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, err
+		}
+		records = append(records, priceIndexRecord{Month: strings.TrimSpace(row[0]), Value: value})
+	}
+	return records, nil
+}
+
+func (storage *PriceIndexFileStorage) GetIndex(at time.Time) (float64, error) {
+	month := at.Format(priceIndexMonthLayout)
+	i := sort.SearchStrings(storage.months, month) // first month not before the wanted one
+	if i < len(storage.months) && storage.months[i] == month {
+		return storage.valueByMonth[month], nil
+	}
+	if i == 0 {
+		return 0, model.ErrPriceIndexNotFound
+	}
+	return storage.valueByMonth[storage.months[i-1]], nil
+}
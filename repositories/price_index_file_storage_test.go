@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vituchon/splitify/model"
+)
+
+func TestPriceIndexFileStorageGetIndex(t *testing.T) {
+	files := []struct {
+		name    string
+		content string
+	}{
+		{name: "indexes.json", content: `{"indexes": [{"month": "2024-03", "value": 300}, {"month": "2024-01", "value": 100.5}, {"month": "2024-02", "value": 200}]}`},
+		{name: "indexes.csv", content: "month,value\n2024-03,300\n2024-01,100.5\n2024-02,200\n"},
+		{name: "without_header.csv", content: "2024-01,100.5\n2024-02, 200\n2024-03,300\n"},
+		{name: "upper_case_extension.CSV", content: "2024-01,100.5\n2024-02,200\n2024-03,300\n"},
+	}
+	tests := []struct {
+		name          string
+		at            time.Time
+		expectedIndex float64
+		expectedError error
+	}{
+		{name: "First month", at: day("2024-01-31"), expectedIndex: 100.5},
+		{name: "Last month", at: day("2024-03-01"), expectedIndex: 300},
+		{name: "Latest earlier month when missing", at: day("2024-08-15"), expectedIndex: 300},
+		{name: "Before the first month", at: day("2023-12-31"), expectedError: model.ErrPriceIndexNotFound},
+	}
+
+	for _, file := range files {
+		t.Run(file.name, func(t *testing.T) {
+			storage, err := NewPriceIndexFileStorage(writeTestFile(t, file.name, file.content))
+			if err != nil {
+				t.Fatalf("Failed to read price indexes: %v", err)
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					index, err := storage.GetIndex(test.at)
+					if err != test.expectedError {
+						t.Fatalf("got error %v, expected %v", err, test.expectedError)
+					}
+					if index != test.expectedIndex {
+						t.Errorf("got index %v, expected %v", index, test.expectedIndex)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestNewPriceIndexFileStorageRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		content       string
+		expectedError error // nil when any error will do
+	}{
+		{name: "Duplicated month in json", file: "indexes.json", content: `{"indexes": [{"month": "2024-01", "value": 100}, {"month": "2024-01", "value": 110}]}`, expectedError: DuplicatedEntityErr},
+		{name: "Duplicated month in csv", file: "indexes.csv", content: "2024-01,100\n2024-02,200\n2024-01,110\n", expectedError: DuplicatedEntityErr},
+		{name: "Zero value", file: "indexes.json", content: `{"indexes": [{"month": "2024-01", "value": 0}]}`, expectedError: model.ErrInvalidPriceIndex},
+		{name: "Negative value", file: "indexes.csv", content: "2024-01,-100\n", expectedError: model.ErrInvalidPriceIndex},
+		{name: "Too many columns", file: "indexes.csv", content: "2024-01,100,ipc\n", expectedError: InvalidEntityStateErr},
+		{name: "Invalid value after the header", file: "indexes.csv", content: "month,value\n2024-01,cien\n"},
+		{name: "Invalid month", file: "indexes.json", content: `{"indexes": [{"month": "01/2024", "value": 100}]}`},
+		{name: "Not json", file: "indexes.json", content: "2024-01,100\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewPriceIndexFileStorage(writeTestFile(t, test.file, test.content))
+			if err == nil || (test.expectedError != nil && err != test.expectedError) {
+				t.Errorf("got error %v, expected %v", err, test.expectedError)
+			}
+		})
+	}
+}
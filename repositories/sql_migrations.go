@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// A versioned schema change, migrations are applied in ascending version order and each one at most once
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+var InvalidMigrationsErr error = errors.New("Migrations must have strictly ascending positive versions")
+
+const createSchemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL
+)`
+
+// Upgrades the schema by applying, each one inside its own transaction, the migrations whose version is greater than the current one.
+// The applied versions are recorded in the schema_migrations table, they are read and upgraded while holding the dialect's
+// migrations lock on a single connection.
+func RunMigrations(ctx context.Context, db *sql.DB, dialect SqlDialect, migrations []Migration) error {
+	previousVersion := 0
+	for _, migration := range migrations {
+		if migration.Version <= previousVersion {
+			return InvalidMigrationsErr
+		}
+		previousVersion = migration.Version
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	lock, unlock := dialect.MigrationsLock()
+	if lock != "" {
+		_, err = conn.ExecContext(ctx, lock)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_, err := conn.ExecContext(context.Background(), unlock)
+			if err != nil {
+				conn.Raw(func(any) error { return driver.ErrBadConn }) // discards the connection, its session and so the lock end with it
+			}
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, createSchemaMigrationsTable)
+	if err != nil {
+		return err
+	}
+	var currentVersion int
+	err = conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&currentVersion)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.Version <= currentVersion {
+			continue
+		}
+		err = applyMigration(ctx, conn, dialect, migration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, dialect SqlDialect, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op once committed
+	for _, statement := range migration.Statements {
+		_, err = tx.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, dialect.Rebind("INSERT INTO schema_migrations (version, description) VALUES (?, ?)"), migration.Version, migration.Description)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vituchon/splitify/model"
+)
+
+// The sql repositories of every entity over the same database, transactions are the database's own ones
+type SqlStorage struct {
+	db           *sql.DB
+	dialect      SqlDialect
+	queryTimeout time.Duration   // optional, how long each query may take before it is cancelled, in a transaction or not
+	ctx          context.Context // optional, the queries and transactions are cancelled along with it
+}
+
+var _ TransactionalStorage = (*SqlStorage)(nil)
+
+func NewSqlStorage(db *sql.DB, dialect SqlDialect, queryTimeout time.Duration) *SqlStorage {
+	return &SqlStorage{db: db, dialect: dialect, queryTimeout: queryTimeout}
+}
+
+func (storage *SqlStorage) Repositories() Repositories {
+	return newSqlRepositories(storage.context(), storage.db, storage.dialect, storage.queryTimeout)
+}
+
+func (storage *SqlStorage) WithContext(ctx context.Context) TransactionalStorage {
+	withContext := *storage
+	withContext.ctx = ctx
+	return &withContext
+}
+
+func (storage *SqlStorage) context() context.Context {
+	if storage.ctx == nil {
+		return context.Background()
+	}
+	return storage.ctx
+}
+
+func newSqlRepositories(ctx context.Context, db SqlExecutor, dialect SqlDialect, queryTimeout time.Duration) Repositories {
+	groups := NewEntitiesSqlStorage[*model.Group](db, dialect, "groups", "", nil)
+	participants := NewParticipantsSqlRepository(db, dialect)
+	movements := NewMovementsSqlRepository(db, dialect)
+	participantMovements := NewParticipantMovementsSqlRepository(db, dialect)
+	groups.SetContext(ctx, queryTimeout)
+	participants.SetContext(ctx, queryTimeout)
+	movements.SetContext(ctx, queryTimeout)
+	participantMovements.SetContext(ctx, queryTimeout)
+	return Repositories{
+		Groups:               groups,
+		Participants:         participants,
+		Movements:            movements,
+		ParticipantMovements: participantMovements,
+	}
+}
+
+// The transactions are serializable, as the balances read inside a transaction (e.g. to settle up a group) must still hold when it
+// commits. Sqlite ones always are, postgres aborts the one conflicting with a concurrent transaction, which RunInTransaction runs again.
+func (storage *SqlStorage) Begin() (Transaction, error) {
+	tx, err := storage.db.BeginTx(storage.context(), &sql.TxOptions{Isolation: sql.LevelSerializable}) // rolled back by the database/sql package if the context is cancelled before committing
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTransaction{tx: tx, repositories: newSqlRepositories(storage.context(), tx, storage.dialect, storage.queryTimeout)}, nil
+}
+
+func (storage *SqlStorage) ShouldRetry(err error) bool {
+	return storage.dialect.IsSerializationFailure(err)
+}
+
+func (storage *SqlStorage) Close() error {
+	return storage.db.Close()
+}
+
+type sqlTransaction struct {
+	tx           *sql.Tx
+	repositories Repositories
+}
+
+func (tx *sqlTransaction) Repositories() Repositories {
+	return tx.repositories
+}
+
+func (tx *sqlTransaction) Commit() error {
+	return translateTxDone(tx.tx.Commit())
+}
+
+func (tx *sqlTransaction) Rollback() error {
+	return translateTxDone(tx.tx.Rollback())
+}
+
+func translateTxDone(err error) error {
+	if err == sql.ErrTxDone {
+		return TransactionFinishedErr
+	}
+	return err
+}
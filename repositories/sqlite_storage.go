@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+type SqliteDialect struct{}
+
+func (SqliteDialect) Rebind(query string) string {
+	return query
+}
+
+// the transaction of each migration already holds the database file, which can only have a single writer
+func (SqliteDialect) MigrationsLock() (string, string) {
+	return "", ""
+}
+
+// the writers wait for each other (up to the busy timeout) instead of failing
+func (SqliteDialect) IsSerializationFailure(err error) bool {
+	return false
+}
+
+var sqliteMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create entities tables",
+		Statements: []string{
+			"CREATE TABLE groups (id INTEGER PRIMARY KEY AUTOINCREMENT, data TEXT NOT NULL)",
+			"CREATE TABLE participants (id INTEGER PRIMARY KEY AUTOINCREMENT, group_id INTEGER NOT NULL, data TEXT NOT NULL)",
+			"CREATE TABLE movements (id INTEGER PRIMARY KEY AUTOINCREMENT, group_id INTEGER NOT NULL, data TEXT NOT NULL)",
+			"CREATE TABLE participant_movements (id INTEGER PRIMARY KEY AUTOINCREMENT, movement_id INTEGER NOT NULL, data TEXT NOT NULL)",
+		},
+	},
+	{
+		Version:     2,
+		Description: "index lookups by group and by movement",
+		Statements: []string{
+			"CREATE INDEX participants_group_id_idx ON participants (group_id)",
+			"CREATE INDEX movements_group_id_idx ON movements (group_id)",
+			"CREATE INDEX participant_movements_movement_id_idx ON participant_movements (movement_id)",
+		},
+	},
+}
+
+// Opens (creating it if needed) the sqlite database file and upgrades its schema
+func OpenSqliteDatabase(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // sqlite allows a single writer, sharing the connection avoids "database is locked" errors
+	err = RunMigrations(context.Background(), db, SqliteDialect{}, sqliteMigrations)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vituchon/splitify/model"
+)
+
+// The behaviour every TransactionalStorage implementation must have, a new backend is verified by running it from its tests, e.g.
+//
+//	TransactionalStorageConformance{
+//		NewStorage: func(t *testing.T) TransactionalStorage { return NewMemoryStorage() },
+//	}.Run(t)
+type TransactionalStorageConformance struct {
+	NewStorage func(t *testing.T) TransactionalStorage // an empty storage
+}
+
+func (suite TransactionalStorageConformance) Run(t *testing.T) {
+	t.Run("Commit keeps the changes", suite.testCommit)
+	t.Run("Rollback discards the changes", suite.testRollback)
+	t.Run("A finished transaction can not be finished again", suite.testFinished)
+}
+
+func (suite TransactionalStorageConformance) testCommit(t *testing.T) {
+	storage := suite.NewStorage(t)
+	tx, err := storage.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	group, movement := saveConformanceMovement(t, tx.Repositories())
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	repos := storage.Repositories()
+	_, err = repos.Groups.GetById(group.Id)
+	if err != nil {
+		t.Errorf("Expected the group to be kept, got %v", err)
+	}
+	movements, err := repos.Movements.GetByGroupId(group.Id)
+	if err != nil || len(movements) != 1 {
+		t.Errorf("Expected the movement to be kept, got %v (error %v)", movements, err)
+	}
+	participantMovements, err := repos.ParticipantMovements.GetByMovementId(movement.Id)
+	if err != nil || len(participantMovements) != 1 {
+		t.Errorf("Expected the participant movement to be kept, got %v (error %v)", participantMovements, err)
+	}
+}
+
+func (suite TransactionalStorageConformance) testRollback(t *testing.T) {
+	storage := suite.NewStorage(t)
+	group, err := storage.Repositories().Groups.Save(&model.Group{Name: "Antes", Currency: model.DefaultCurrency})
+	if err != nil {
+		t.Fatalf("Failed to save group: %v", err)
+	}
+	participant, err := storage.Repositories().Participants.Save(&model.Participant{Name: "Ana", GroupId: group.Id, Weight: 1})
+	if err != nil {
+		t.Fatalf("Failed to save participant: %v", err)
+	}
+
+	tx, err := storage.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	repos := tx.Repositories()
+	changed, err := repos.Groups.GetById(group.Id)
+	if err != nil {
+		t.Fatalf("Failed to get group: %v", err)
+	}
+	changed.Name = "Después" // changed in place, as callers do before updating
+	changed.SettlementUnits = []model.SettlementUnit{{ParticipantIds: []int{participant.Id}}}
+	_, err = repos.Groups.Update(changed)
+	if err != nil {
+		t.Fatalf("Failed to update group: %v", err)
+	}
+	err = repos.Participants.Delete(participant.Id)
+	if err != nil {
+		t.Fatalf("Failed to delete participant: %v", err)
+	}
+	savedGroup, movement := saveConformanceMovement(t, repos)
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+
+	repos = storage.Repositories()
+	groups, err := repos.Groups.GetAll()
+	if err != nil {
+		t.Fatalf("Failed to get groups: %v", err)
+	}
+	expectedGroups := []*model.Group{{Id: group.Id, Name: "Antes", Currency: model.DefaultCurrency}}
+	if !reflect.DeepEqual(groups, expectedGroups) {
+		t.Errorf("got groups %+v, expected %+v", groups, expectedGroups)
+	}
+	participants, err := repos.Participants.GetByGroupId(group.Id)
+	if err != nil || len(participants) != 1 || participants[0].Id != participant.Id {
+		t.Errorf("Expected the participant to be kept, got %v (error %v)", participants, err)
+	}
+	movements, err := repos.Movements.GetByGroupId(savedGroup.Id)
+	if err != nil || len(movements) != 0 {
+		t.Errorf("Expected the movement to be discarded, got %v (error %v)", movements, err)
+	}
+	participantMovements, err := repos.ParticipantMovements.GetByMovementId(movement.Id)
+	if err != nil || len(participantMovements) != 0 {
+		t.Errorf("Expected the participant movement to be discarded, got %v (error %v)", participantMovements, err)
+	}
+}
+
+func (suite TransactionalStorageConformance) testFinished(t *testing.T) {
+	storage := suite.NewStorage(t)
+	tx, err := storage.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	err = tx.Commit()
+	if err != TransactionFinishedErr {
+		t.Errorf("got error %v, expected %v", err, TransactionFinishedErr)
+	}
+	err = tx.Rollback()
+	if err != TransactionFinishedErr {
+		t.Errorf("got error %v, expected %v", err, TransactionFinishedErr)
+	}
+}
+
+// saves a group with a movement paid by one of its participants
+func saveConformanceMovement(t *testing.T, repos Repositories) (*model.Group, *model.Movement) {
+	t.Helper()
+	group, err := repos.Groups.Save(&model.Group{Name: "Durante", Currency: model.DefaultCurrency})
+	if err != nil {
+		t.Fatalf("Failed to save group: %v", err)
+	}
+	participant, err := repos.Participants.Save(&model.Participant{Name: "Bruno", GroupId: group.Id, Weight: 1})
+	if err != nil {
+		t.Fatalf("Failed to save participant: %v", err)
+	}
+	movement, err := repos.Movements.Save(&model.Movement{GroupId: group.Id, Amount: 100, Currency: model.DefaultCurrency, ExchangeRate: 1, Concept: "Café", Kind: model.ExpenseKind})
+	if err != nil {
+		t.Fatalf("Failed to save movement: %v", err)
+	}
+	_, err = repos.ParticipantMovements.Save(&model.ParticipantMovement{MovementId: movement.Id, ParticipantId: participant.Id, Amount: 100, Weight: 1})
+	if err != nil {
+		t.Fatalf("Failed to save participant movement: %v", err)
+	}
+	return group, movement
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vituchon/splitify/model"
+)
+
+var TransactionFinishedErr error = errors.New("Transaction already committed or rolled back")
+
+// The repositories of every entity of the model, backed by the same storage
+type Repositories struct {
+	Groups               EntitiesRepository[*model.Group]
+	Participants         ParticipantsRepository
+	Movements            MovementsRepository
+	ParticipantMovements ParticipantMovementsRepository
+}
+
+// The changes made through the transaction's repositories are either all kept (commit) or all discarded (rollback).
+// Once finished, the transaction and its repositories must not be used anymore.
+type Transaction interface {
+	Repositories() Repositories
+	Commit() error
+	Rollback() error
+}
+
+type TransactionalStorage interface {
+	Repositories() Repositories
+	Begin() (Transaction, error)
+	WithContext(ctx context.Context) TransactionalStorage // the same storage with its work (queries and transactions) cancelled along with the context
+}
+
+const transactionAttempts = 3 // how many times a transaction aborted because of a concurrent one is run
+
+// Implemented by the storages whose transactions may be aborted because of a concurrent one
+type RetryingStorage interface {
+	ShouldRetry(err error) bool // whether the transaction failed with the error was aborted because of a concurrent one
+}
+
+// Runs the work inside a transaction that is committed when the work succeeds and rolled back otherwise (or when it panics).
+// When the storage aborts the transaction because of a concurrent one, the work is run again in a new transaction, so it must
+// not keep anything from a previous run.
+func RunInTransaction(storage TransactionalStorage, work func(repositories Repositories) error) error {
+	retrying, canRetry := storage.(RetryingStorage)
+	for attempt := 1; ; attempt++ {
+		err := runInTransaction(storage, work)
+		if err == nil || !canRetry || attempt == transactionAttempts || !retrying.ShouldRetry(err) {
+			return err
+		}
+	}
+}
+
+func runInTransaction(storage TransactionalStorage, work func(repositories Repositories) error) error {
+	tx, err := storage.Begin()
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+	err = work(tx.Repositories())
+	if err != nil {
+		return err
+	}
+	committed = true // a failed commit leaves nothing to roll back
+	return tx.Commit()
+}
+
+// The storages kept in memory (even the durable ones keep a copy) undo a transaction by putting back, in reverse order,
+// what each change replaced
+type undoableStorage[E Identificable] interface {
+	getAll() []E
+	getById(id int) (E, bool)
+	save(entity E) (E, error)
+	update(entity E) (E, error)
+	delete(id int) error
+	getIdSequence() int
+	restore(id int, entity E, existed bool, idSequence int) // only in memory, the durable storages never wrote the undone changes
+}
+
+type undoLog struct {
+	undos []func()
+}
+
+func (log *undoLog) undo() {
+	for i := len(log.undos) - 1; i >= 0; i-- {
+		log.undos[i]()
+	}
+	log.undos = nil
+}
+
+// A repository that records in the undo log how to revert each change made through it
+type undoLogRepository[E Identificable] struct {
+	storage undoableStorage[E]
+	log     *undoLog
+}
+
+func (repo undoLogRepository[E]) GetAll() ([]E, error) {
+	return repo.storage.getAll(), nil
+}
+
+func (repo undoLogRepository[E]) GetById(id int) (E, error) {
+	entity, exists := repo.storage.getById(id)
+	if !exists {
+		return entity, EntityNotExistsErr
+	}
+	return entity, nil
+}
+
+func (repo undoLogRepository[E]) Save(entity E) (E, error) {
+	idSequence := repo.storage.getIdSequence()
+	saved, err := repo.storage.save(entity)
+	if err != nil {
+		return saved, err
+	}
+	var zeroValue E
+	id := saved.GetId()
+	repo.log.undos = append(repo.log.undos, func() {
+		repo.storage.restore(id, zeroValue, false, idSequence)
+	})
+	return saved, nil
+}
+
+func (repo undoLogRepository[E]) Update(entity E) (E, error) {
+	return repo.change(entity.GetId(), func() (E, error) {
+		return repo.storage.update(entity)
+	})
+}
+
+func (repo undoLogRepository[E]) Delete(id int) error {
+	_, err := repo.change(id, func() (E, error) {
+		var zeroValue E
+		return zeroValue, repo.storage.delete(id)
+	})
+	return err
+}
+
+func (repo undoLogRepository[E]) change(id int, apply func() (E, error)) (E, error) {
+	idSequence := repo.storage.getIdSequence()
+	previous, existed := repo.storage.getById(id)
+	entity, err := apply()
+	if err != nil {
+		return entity, err
+	}
+	repo.log.undos = append(repo.log.undos, func() {
+		repo.storage.restore(id, previous, existed, idSequence)
+	})
+	return entity, nil
+}
+
+type participantsUndoLogRepository struct {
+	undoLogRepository[*model.Participant]
+	getByGroupId func(groupId int) []*model.Participant
+}
+
+func (repo participantsUndoLogRepository) GetByGroupId(groupId int) ([]*model.Participant, error) {
+	return repo.getByGroupId(groupId), nil
+}
+
+type movementsUndoLogRepository struct {
+	undoLogRepository[*model.Movement]
+	getByGroupId func(groupId int) []*model.Movement
+}
+
+func (repo movementsUndoLogRepository) GetByGroupId(groupId int) ([]*model.Movement, error) {
+	return repo.getByGroupId(groupId), nil
+}
+
+type participantMovementsUndoLogRepository struct {
+	undoLogRepository[*model.ParticipantMovement]
+	getByMovementId func(movementId int) []*model.ParticipantMovement
+}
+
+func (repo participantMovementsUndoLogRepository) GetByMovementId(movementId int) ([]*model.ParticipantMovement, error) {
+	return repo.getByMovementId(movementId), nil
+}